@@ -0,0 +1,199 @@
+// Package admission protects the servers from more work than they can do at
+// once. It limits how many prime generators run at the same time, and how
+// many expensive calls (those asking for a large number of primes) are in
+// progress. A call which cannot start straight away waits briefly for a slot,
+// and is rejected with Unavailable if none frees up, so that clients back off
+// and retry rather than piling more work onto a busy server.
+package admission
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/devries/grpc-tutorial/richstatus"
+)
+
+// Defaults used when the corresponding environment variables are unset.
+const (
+	DefaultWait               = time.Second
+	DefaultExpensiveThreshold = 100000
+)
+
+var (
+	inUse = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "primes_admission_in_use",
+		Help: "Number of slots in use, by pool.",
+	}, []string{"pool"})
+
+	limit = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "primes_admission_limit",
+		Help: "Number of slots available, by pool. Unlimited pools are not reported.",
+	}, []string{"pool"})
+
+	rejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "primes_admission_rejected_total",
+		Help: "Number of calls rejected because no slot became free, by pool.",
+	}, []string{"pool"})
+
+	waited = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "primes_admission_wait_seconds",
+		Help:    "Time calls waited for a slot, by pool.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+	}, []string{"pool"})
+)
+
+// pool is a counting semaphore. A nil pool has no limit.
+type pool struct {
+	name  string
+	slots chan struct{}
+}
+
+func newPool(name string, size int) *pool {
+	if size == 0 {
+		return nil
+	}
+
+	limit.WithLabelValues(name).Set(float64(size))
+	return &pool{name: name, slots: make(chan struct{}, size)}
+}
+
+// acquire takes a slot, waiting at most wait for one to free up.
+func (p *pool) acquire(ctx context.Context, wait time.Duration) error {
+	if p == nil {
+		return nil
+	}
+
+	start := time.Now()
+	select {
+	case p.slots <- struct{}{}:
+		waited.WithLabelValues(p.name).Observe(0)
+		inUse.WithLabelValues(p.name).Inc()
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case p.slots <- struct{}{}:
+		waited.WithLabelValues(p.name).Observe(time.Since(start).Seconds())
+		inUse.WithLabelValues(p.name).Inc()
+		return nil
+	case <-timer.C:
+		rejected.WithLabelValues(p.name).Inc()
+		return richstatus.RetryAfter(codes.Unavailable, fmt.Sprintf("server is busy (no free %s), try again later", p.name), wait)
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (p *pool) release() {
+	if p == nil {
+		return
+	}
+
+	<-p.slots
+	inUse.WithLabelValues(p.name).Dec()
+}
+
+// Controller decides whether calls may start. A nil Controller admits
+// everything.
+type Controller struct {
+	generators *pool
+	expensive  *pool
+	threshold  int64
+	wait       time.Duration
+}
+
+// New returns a controller allowing maxGenerators prime generators, and
+// maxExpensive calls for threshold or more primes, to run at once. Calls
+// wait up to wait for a slot. A maximum of zero is unlimited.
+func New(maxGenerators, maxExpensive int, threshold int64, wait time.Duration) *Controller {
+	return &Controller{
+		generators: newPool("generators", maxGenerators),
+		expensive:  newPool("expensive_calls", maxExpensive),
+		threshold:  threshold,
+		wait:       wait,
+	}
+}
+
+// FromEnv returns a controller configured by the MAX_GENERATORS,
+// MAX_EXPENSIVE_CALLS, EXPENSIVE_THRESHOLD (primes), and ADMISSION_WAIT
+// (a duration such as "500ms") environment variables. It returns nil if
+// neither maximum is set.
+func FromEnv() (*Controller, error) {
+	maxGenerators, err := intFromEnv("MAX_GENERATORS", 0)
+	if err != nil {
+		return nil, err
+	}
+	maxExpensive, err := intFromEnv("MAX_EXPENSIVE_CALLS", 0)
+	if err != nil {
+		return nil, err
+	}
+	if maxGenerators == 0 && maxExpensive == 0 {
+		return nil, nil
+	}
+
+	threshold, err := intFromEnv("EXPENSIVE_THRESHOLD", DefaultExpensiveThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	wait := DefaultWait
+	if v := os.Getenv("ADMISSION_WAIT"); v != "" {
+		wait, err = time.ParseDuration(v)
+		if err != nil || wait < 0 {
+			return nil, fmt.Errorf("ADMISSION_WAIT must be a non-negative duration such as 500ms, not %q", v)
+		}
+	}
+
+	return New(maxGenerators, maxExpensive, int64(threshold), wait), nil
+}
+
+func intFromEnv(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, not %q", name, v)
+	}
+	return n, nil
+}
+
+// Admit waits for the slots needed to generate n primes: a generator, and if
+// n is at least the expensive threshold an expensive call. The returned
+// function gives the slots back and must be called when the call finishes.
+func (c *Controller) Admit(ctx context.Context, n int64) (func(), error) {
+	if c == nil {
+		return func() {}, nil
+	}
+
+	expensive := c.expensive
+	if n < c.threshold {
+		expensive = nil
+	}
+
+	if err := expensive.acquire(ctx, c.wait); err != nil {
+		return nil, err
+	}
+	if err := c.generators.acquire(ctx, c.wait); err != nil {
+		expensive.release()
+		return nil, err
+	}
+
+	return func() {
+		c.generators.release()
+		expensive.release()
+	}, nil
+}
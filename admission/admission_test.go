@@ -0,0 +1,92 @@
+package admission
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const wait = 20 * time.Millisecond
+
+func admit(t *testing.T, c *Controller, n int64) func() {
+	t.Helper()
+
+	release, err := c.Admit(context.Background(), n)
+	if err != nil {
+		t.Fatalf("call for %d primes not admitted: %s", n, err)
+	}
+	return release
+}
+
+func expectBusy(t *testing.T, c *Controller, n int64) {
+	t.Helper()
+
+	start := time.Now()
+	_, err := c.Admit(context.Background(), n)
+	if code := status.Code(err); code != codes.Unavailable {
+		t.Fatalf("call for %d primes got %s (%v), want %s", n, code, err, codes.Unavailable)
+	}
+	if waited := time.Since(start); waited < wait {
+		t.Errorf("rejected after %s, before waiting %s", waited, wait)
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	c := New(2, 0, 100, wait)
+
+	first := admit(t, c, 10)
+	admit(t, c, 10)
+	expectBusy(t, c, 10)
+
+	// A released slot is reused.
+	first()
+	admit(t, c, 10)
+	expectBusy(t, c, 10)
+}
+
+func TestWaitForSlot(t *testing.T) {
+	c := New(1, 0, 100, time.Second)
+
+	release := admit(t, c, 10)
+	time.AfterFunc(10*time.Millisecond, release)
+
+	// The call waits for the slot to be released.
+	admit(t, c, 10)
+}
+
+func TestExpensiveCalls(t *testing.T) {
+	c := New(2, 1, 100, wait)
+
+	expensive := admit(t, c, 100)
+	expectBusy(t, c, 1000)
+
+	// Cheap calls only need a generator.
+	admit(t, c, 99)
+
+	// Releasing the expensive call frees its generator and its slot for
+	// expensive calls.
+	expensive()
+	admit(t, c, 100)
+	expectBusy(t, c, 10)
+}
+
+func TestCancelled(t *testing.T) {
+	c := New(1, 0, 100, time.Second)
+	admit(t, c, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.Admit(ctx, 10)
+	if code := status.Code(err); code != codes.Canceled {
+		t.Errorf("got %s (%v), want %s", code, err, codes.Canceled)
+	}
+}
+
+func TestNil(t *testing.T) {
+	var c *Controller
+	release := admit(t, c, 1000000)
+	release()
+}
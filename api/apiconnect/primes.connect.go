@@ -0,0 +1,114 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: primes.proto
+
+package apiconnect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	errors "errors"
+	api "github.com/devries/grpc-tutorial/api"
+	http "net/http"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// PrimesName is the fully-qualified name of the Primes service.
+	PrimesName = "api.Primes"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// PrimesGetPrimesProcedure is the fully-qualified name of the Primes's GetPrimes RPC.
+	PrimesGetPrimesProcedure = "/api.Primes/GetPrimes"
+)
+
+// These variables are the protoreflect.Descriptor objects for the RPCs defined in this package.
+var (
+	primesServiceDescriptor         = api.File_primes_proto.Services().ByName("Primes")
+	primesGetPrimesMethodDescriptor = primesServiceDescriptor.Methods().ByName("GetPrimes")
+)
+
+// PrimesClient is a client for the api.Primes service.
+type PrimesClient interface {
+	// Served over HTTP by the REST gateway as GET /v1/primes?number=5.
+	GetPrimes(context.Context, *connect.Request[api.PrimeCount]) (*connect.Response[api.PrimeNumbers], error)
+}
+
+// NewPrimesClient constructs a client for the api.Primes service. By default, it uses the Connect
+// protocol with the binary Protobuf Codec, asks for gzipped responses, and sends uncompressed
+// requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC() or
+// connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewPrimesClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) PrimesClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &primesClient{
+		getPrimes: connect.NewClient[api.PrimeCount, api.PrimeNumbers](
+			httpClient,
+			baseURL+PrimesGetPrimesProcedure,
+			connect.WithSchema(primesGetPrimesMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// primesClient implements PrimesClient.
+type primesClient struct {
+	getPrimes *connect.Client[api.PrimeCount, api.PrimeNumbers]
+}
+
+// GetPrimes calls api.Primes.GetPrimes.
+func (c *primesClient) GetPrimes(ctx context.Context, req *connect.Request[api.PrimeCount]) (*connect.Response[api.PrimeNumbers], error) {
+	return c.getPrimes.CallUnary(ctx, req)
+}
+
+// PrimesHandler is an implementation of the api.Primes service.
+type PrimesHandler interface {
+	// Served over HTTP by the REST gateway as GET /v1/primes?number=5.
+	GetPrimes(context.Context, *connect.Request[api.PrimeCount]) (*connect.Response[api.PrimeNumbers], error)
+}
+
+// NewPrimesHandler builds an HTTP handler from the service implementation. It returns the path on
+// which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewPrimesHandler(svc PrimesHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	primesGetPrimesHandler := connect.NewUnaryHandler(
+		PrimesGetPrimesProcedure,
+		svc.GetPrimes,
+		connect.WithSchema(primesGetPrimesMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/api.Primes/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case PrimesGetPrimesProcedure:
+			primesGetPrimesHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedPrimesHandler returns CodeUnimplemented from all methods.
+type UnimplementedPrimesHandler struct{}
+
+func (UnimplementedPrimesHandler) GetPrimes(context.Context, *connect.Request[api.PrimeCount]) (*connect.Response[api.PrimeNumbers], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("api.Primes.GetPrimes is not implemented"))
+}
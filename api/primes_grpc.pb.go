@@ -0,0 +1,123 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: primes.proto
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Primes_GetPrimes_FullMethodName = "/api.Primes/GetPrimes"
+)
+
+// PrimesClient is the client API for Primes service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PrimesClient interface {
+	// Served over HTTP by the REST gateway as GET /v1/primes?number=5.
+	GetPrimes(ctx context.Context, in *PrimeCount, opts ...grpc.CallOption) (*PrimeNumbers, error)
+}
+
+type primesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPrimesClient(cc grpc.ClientConnInterface) PrimesClient {
+	return &primesClient{cc}
+}
+
+func (c *primesClient) GetPrimes(ctx context.Context, in *PrimeCount, opts ...grpc.CallOption) (*PrimeNumbers, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PrimeNumbers)
+	err := c.cc.Invoke(ctx, Primes_GetPrimes_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PrimesServer is the server API for Primes service.
+// All implementations must embed UnimplementedPrimesServer
+// for forward compatibility.
+type PrimesServer interface {
+	// Served over HTTP by the REST gateway as GET /v1/primes?number=5.
+	GetPrimes(context.Context, *PrimeCount) (*PrimeNumbers, error)
+	mustEmbedUnimplementedPrimesServer()
+}
+
+// UnimplementedPrimesServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPrimesServer struct{}
+
+func (UnimplementedPrimesServer) GetPrimes(context.Context, *PrimeCount) (*PrimeNumbers, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPrimes not implemented")
+}
+func (UnimplementedPrimesServer) mustEmbedUnimplementedPrimesServer() {}
+func (UnimplementedPrimesServer) testEmbeddedByValue()                {}
+
+// UnsafePrimesServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PrimesServer will
+// result in compilation errors.
+type UnsafePrimesServer interface {
+	mustEmbedUnimplementedPrimesServer()
+}
+
+func RegisterPrimesServer(s grpc.ServiceRegistrar, srv PrimesServer) {
+	// If the following call pancis, it indicates UnimplementedPrimesServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Primes_ServiceDesc, srv)
+}
+
+func _Primes_GetPrimes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrimeCount)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PrimesServer).GetPrimes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Primes_GetPrimes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PrimesServer).GetPrimes(ctx, req.(*PrimeCount))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Primes_ServiceDesc is the grpc.ServiceDesc for Primes service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Primes_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "api.Primes",
+	HandlerType: (*PrimesServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetPrimes",
+			Handler:    _Primes_GetPrimes_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "primes.proto",
+}
@@ -0,0 +1,116 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: primestream.proto
+
+package apistreamconnect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	errors "errors"
+	apistream "github.com/devries/grpc-tutorial/apistream"
+	http "net/http"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// PrimeStreamName is the fully-qualified name of the PrimeStream service.
+	PrimeStreamName = "apistream.PrimeStream"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// PrimeStreamGetPrimesProcedure is the fully-qualified name of the PrimeStream's GetPrimes RPC.
+	PrimeStreamGetPrimesProcedure = "/apistream.PrimeStream/GetPrimes"
+)
+
+// These variables are the protoreflect.Descriptor objects for the RPCs defined in this package.
+var (
+	primeStreamServiceDescriptor         = apistream.File_primestream_proto.Services().ByName("PrimeStream")
+	primeStreamGetPrimesMethodDescriptor = primeStreamServiceDescriptor.Methods().ByName("GetPrimes")
+)
+
+// PrimeStreamClient is a client for the apistream.PrimeStream service.
+type PrimeStreamClient interface {
+	// Served over HTTP by the REST gateway as GET /v1/primes:stream?number=5,
+	// which returns one JSON object per line as the primes are found.
+	GetPrimes(context.Context, *connect.Request[apistream.PrimeCount]) (*connect.ServerStreamForClient[apistream.PrimeNumber], error)
+}
+
+// NewPrimeStreamClient constructs a client for the apistream.PrimeStream service. By default, it
+// uses the Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and sends
+// uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC() or
+// connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewPrimeStreamClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) PrimeStreamClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &primeStreamClient{
+		getPrimes: connect.NewClient[apistream.PrimeCount, apistream.PrimeNumber](
+			httpClient,
+			baseURL+PrimeStreamGetPrimesProcedure,
+			connect.WithSchema(primeStreamGetPrimesMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// primeStreamClient implements PrimeStreamClient.
+type primeStreamClient struct {
+	getPrimes *connect.Client[apistream.PrimeCount, apistream.PrimeNumber]
+}
+
+// GetPrimes calls apistream.PrimeStream.GetPrimes.
+func (c *primeStreamClient) GetPrimes(ctx context.Context, req *connect.Request[apistream.PrimeCount]) (*connect.ServerStreamForClient[apistream.PrimeNumber], error) {
+	return c.getPrimes.CallServerStream(ctx, req)
+}
+
+// PrimeStreamHandler is an implementation of the apistream.PrimeStream service.
+type PrimeStreamHandler interface {
+	// Served over HTTP by the REST gateway as GET /v1/primes:stream?number=5,
+	// which returns one JSON object per line as the primes are found.
+	GetPrimes(context.Context, *connect.Request[apistream.PrimeCount], *connect.ServerStream[apistream.PrimeNumber]) error
+}
+
+// NewPrimeStreamHandler builds an HTTP handler from the service implementation. It returns the path
+// on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewPrimeStreamHandler(svc PrimeStreamHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	primeStreamGetPrimesHandler := connect.NewServerStreamHandler(
+		PrimeStreamGetPrimesProcedure,
+		svc.GetPrimes,
+		connect.WithSchema(primeStreamGetPrimesMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/apistream.PrimeStream/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case PrimeStreamGetPrimesProcedure:
+			primeStreamGetPrimesHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedPrimeStreamHandler returns CodeUnimplemented from all methods.
+type UnimplementedPrimeStreamHandler struct{}
+
+func (UnimplementedPrimeStreamHandler) GetPrimes(context.Context, *connect.Request[apistream.PrimeCount], *connect.ServerStream[apistream.PrimeNumber]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("apistream.PrimeStream.GetPrimes is not implemented"))
+}
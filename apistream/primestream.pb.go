@@ -0,0 +1,232 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.1
+// 	protoc        (unknown)
+// source: primestream.proto
+
+package apistream
+
+import (
+	_ "github.com/devries/grpc-tutorial/validate"
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type PrimeCount struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The number of primes to stream, at most ten million.
+	Number int64 `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`
+}
+
+func (x *PrimeCount) Reset() {
+	*x = PrimeCount{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_primestream_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PrimeCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrimeCount) ProtoMessage() {}
+
+func (x *PrimeCount) ProtoReflect() protoreflect.Message {
+	mi := &file_primestream_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrimeCount.ProtoReflect.Descriptor instead.
+func (*PrimeCount) Descriptor() ([]byte, []int) {
+	return file_primestream_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PrimeCount) GetNumber() int64 {
+	if x != nil {
+		return x.Number
+	}
+	return 0
+}
+
+type PrimeNumber struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Count int64 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Value int64 `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *PrimeNumber) Reset() {
+	*x = PrimeNumber{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_primestream_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PrimeNumber) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrimeNumber) ProtoMessage() {}
+
+func (x *PrimeNumber) ProtoReflect() protoreflect.Message {
+	mi := &file_primestream_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrimeNumber.ProtoReflect.Descriptor instead.
+func (*PrimeNumber) Descriptor() ([]byte, []int) {
+	return file_primestream_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PrimeNumber) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *PrimeNumber) GetValue() int64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+var File_primestream_proto protoreflect.FileDescriptor
+
+var file_primestream_proto_rawDesc = []byte{
+	0x0a, 0x11, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x12, 0x09, 0x61, 0x70, 0x69, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x1a, 0x1c,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x61, 0x6e, 0x6e, 0x6f, 0x74,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x17, 0x76, 0x61,
+	0x6c, 0x69, 0x64, 0x61, 0x74, 0x65, 0x2f, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x61, 0x74, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x33, 0x0a, 0x0a, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x43, 0x6f,
+	0x75, 0x6e, 0x74, 0x12, 0x25, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x03, 0x42, 0x0d, 0xa2, 0xbb, 0x18, 0x09, 0x0a, 0x07, 0x08, 0x00, 0x10, 0x80, 0xad,
+	0xe2, 0x04, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x39, 0x0a, 0x0b, 0x50, 0x72,
+	0x69, 0x6d, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75,
+	0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x12,
+	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x32, 0x66, 0x0a, 0x0b, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x53, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x12, 0x57, 0x0a, 0x09, 0x47, 0x65, 0x74, 0x50, 0x72, 0x69, 0x6d, 0x65,
+	0x73, 0x12, 0x15, 0x2e, 0x61, 0x70, 0x69, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x50, 0x72,
+	0x69, 0x6d, 0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x1a, 0x16, 0x2e, 0x61, 0x70, 0x69, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x2e, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72,
+	0x22, 0x19, 0x82, 0xd3, 0xe4, 0x93, 0x02, 0x13, 0x12, 0x11, 0x2f, 0x76, 0x31, 0x2f, 0x70, 0x72,
+	0x69, 0x6d, 0x65, 0x73, 0x3a, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x30, 0x01, 0x42, 0x2c, 0x5a,
+	0x2a, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64, 0x65, 0x76, 0x72,
+	0x69, 0x65, 0x73, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x2d, 0x74, 0x75, 0x74, 0x6f, 0x72, 0x69, 0x61,
+	0x6c, 0x2f, 0x61, 0x70, 0x69, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
+}
+
+var (
+	file_primestream_proto_rawDescOnce sync.Once
+	file_primestream_proto_rawDescData = file_primestream_proto_rawDesc
+)
+
+func file_primestream_proto_rawDescGZIP() []byte {
+	file_primestream_proto_rawDescOnce.Do(func() {
+		file_primestream_proto_rawDescData = protoimpl.X.CompressGZIP(file_primestream_proto_rawDescData)
+	})
+	return file_primestream_proto_rawDescData
+}
+
+var file_primestream_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_primestream_proto_goTypes = []interface{}{
+	(*PrimeCount)(nil),  // 0: apistream.PrimeCount
+	(*PrimeNumber)(nil), // 1: apistream.PrimeNumber
+}
+var file_primestream_proto_depIdxs = []int32{
+	0, // 0: apistream.PrimeStream.GetPrimes:input_type -> apistream.PrimeCount
+	1, // 1: apistream.PrimeStream.GetPrimes:output_type -> apistream.PrimeNumber
+	1, // [1:2] is the sub-list for method output_type
+	0, // [0:1] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_primestream_proto_init() }
+func file_primestream_proto_init() {
+	if File_primestream_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_primestream_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PrimeCount); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_primestream_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PrimeNumber); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_primestream_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_primestream_proto_goTypes,
+		DependencyIndexes: file_primestream_proto_depIdxs,
+		MessageInfos:      file_primestream_proto_msgTypes,
+	}.Build()
+	File_primestream_proto = out.File
+	file_primestream_proto_rawDesc = nil
+	file_primestream_proto_goTypes = nil
+	file_primestream_proto_depIdxs = nil
+}
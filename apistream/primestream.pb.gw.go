@@ -0,0 +1,147 @@
+// Code generated by protoc-gen-grpc-gateway. DO NOT EDIT.
+// source: primestream.proto
+
+/*
+Package apistream is a reverse proxy.
+
+It translates gRPC into RESTful JSON APIs.
+*/
+package apistream
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Suppress "imported and not used" errors
+var _ codes.Code
+var _ io.Reader
+var _ status.Status
+var _ = runtime.String
+var _ = utilities.NewDoubleArray
+var _ = metadata.Join
+
+var (
+	filter_PrimeStream_GetPrimes_0 = &utilities.DoubleArray{Encoding: map[string]int{}, Base: []int(nil), Check: []int(nil)}
+)
+
+func request_PrimeStream_GetPrimes_0(ctx context.Context, marshaler runtime.Marshaler, client PrimeStreamClient, req *http.Request, pathParams map[string]string) (PrimeStream_GetPrimesClient, runtime.ServerMetadata, error) {
+	var protoReq PrimeCount
+	var metadata runtime.ServerMetadata
+
+	if err := req.ParseForm(); err != nil {
+		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if err := runtime.PopulateQueryParameters(&protoReq, req.Form, filter_PrimeStream_GetPrimes_0); err != nil {
+		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	stream, err := client.GetPrimes(ctx, &protoReq)
+	if err != nil {
+		return nil, metadata, err
+	}
+	header, err := stream.Header()
+	if err != nil {
+		return nil, metadata, err
+	}
+	metadata.HeaderMD = header
+	return stream, metadata, nil
+
+}
+
+// RegisterPrimeStreamHandlerServer registers the http handlers for service PrimeStream to "mux".
+// UnaryRPC     :call PrimeStreamServer directly.
+// StreamingRPC :currently unsupported pending https://github.com/grpc/grpc-go/issues/906.
+// Note that using this registration option will cause many gRPC library features to stop working. Consider using RegisterPrimeStreamHandlerFromEndpoint instead.
+func RegisterPrimeStreamHandlerServer(ctx context.Context, mux *runtime.ServeMux, server PrimeStreamServer) error {
+
+	mux.Handle("GET", pattern_PrimeStream_GetPrimes_0, func(w http.ResponseWriter, req *http.Request, pathParams map[string]string) {
+		err := status.Error(codes.Unimplemented, "streaming calls are not yet supported in the in-process transport")
+		_, outboundMarshaler := runtime.MarshalerForRequest(mux, req)
+		runtime.HTTPError(ctx, mux, outboundMarshaler, w, req, err)
+		return
+	})
+
+	return nil
+}
+
+// RegisterPrimeStreamHandlerFromEndpoint is same as RegisterPrimeStreamHandler but
+// automatically dials to "endpoint" and closes the connection when "ctx" gets done.
+func RegisterPrimeStreamHandlerFromEndpoint(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) (err error) {
+	conn, err := grpc.DialContext(ctx, endpoint, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if cerr := conn.Close(); cerr != nil {
+				grpclog.Infof("Failed to close conn to %s: %v", endpoint, cerr)
+			}
+			return
+		}
+		go func() {
+			<-ctx.Done()
+			if cerr := conn.Close(); cerr != nil {
+				grpclog.Infof("Failed to close conn to %s: %v", endpoint, cerr)
+			}
+		}()
+	}()
+
+	return RegisterPrimeStreamHandler(ctx, mux, conn)
+}
+
+// RegisterPrimeStreamHandler registers the http handlers for service PrimeStream to "mux".
+// The handlers forward requests to the grpc endpoint over "conn".
+func RegisterPrimeStreamHandler(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	return RegisterPrimeStreamHandlerClient(ctx, mux, NewPrimeStreamClient(conn))
+}
+
+// RegisterPrimeStreamHandlerClient registers the http handlers for service PrimeStream
+// to "mux". The handlers forward requests to the grpc endpoint over the given implementation of "PrimeStreamClient".
+// Note: the gRPC framework executes interceptors within the gRPC handler. If the passed in "PrimeStreamClient"
+// doesn't go through the normal gRPC flow (creating a gRPC client etc.) then it will be up to the passed in
+// "PrimeStreamClient" to call the correct interceptors.
+func RegisterPrimeStreamHandlerClient(ctx context.Context, mux *runtime.ServeMux, client PrimeStreamClient) error {
+
+	mux.Handle("GET", pattern_PrimeStream_GetPrimes_0, func(w http.ResponseWriter, req *http.Request, pathParams map[string]string) {
+		ctx, cancel := context.WithCancel(req.Context())
+		defer cancel()
+		inboundMarshaler, outboundMarshaler := runtime.MarshalerForRequest(mux, req)
+		var err error
+		var annotatedContext context.Context
+		annotatedContext, err = runtime.AnnotateContext(ctx, mux, req, "/apistream.PrimeStream/GetPrimes", runtime.WithHTTPPathPattern("/v1/primes:stream"))
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outboundMarshaler, w, req, err)
+			return
+		}
+		resp, md, err := request_PrimeStream_GetPrimes_0(annotatedContext, inboundMarshaler, client, req, pathParams)
+		annotatedContext = runtime.NewServerMetadataContext(annotatedContext, md)
+		if err != nil {
+			runtime.HTTPError(annotatedContext, mux, outboundMarshaler, w, req, err)
+			return
+		}
+
+		forward_PrimeStream_GetPrimes_0(annotatedContext, mux, outboundMarshaler, w, req, func() (proto.Message, error) { return resp.Recv() }, mux.GetForwardResponseOptions()...)
+
+	})
+
+	return nil
+}
+
+var (
+	pattern_PrimeStream_GetPrimes_0 = runtime.MustPattern(runtime.NewPattern(1, []int{2, 0, 2, 1}, []string{"v1", "primes"}, "stream"))
+)
+
+var (
+	forward_PrimeStream_GetPrimes_0 = runtime.ForwardResponseStream
+)
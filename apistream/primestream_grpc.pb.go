@@ -0,0 +1,128 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: primestream.proto
+
+package apistream
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PrimeStream_GetPrimes_FullMethodName = "/apistream.PrimeStream/GetPrimes"
+)
+
+// PrimeStreamClient is the client API for PrimeStream service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PrimeStreamClient interface {
+	// Served over HTTP by the REST gateway as GET /v1/primes:stream?number=5,
+	// which returns one JSON object per line as the primes are found.
+	GetPrimes(ctx context.Context, in *PrimeCount, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PrimeNumber], error)
+}
+
+type primeStreamClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPrimeStreamClient(cc grpc.ClientConnInterface) PrimeStreamClient {
+	return &primeStreamClient{cc}
+}
+
+func (c *primeStreamClient) GetPrimes(ctx context.Context, in *PrimeCount, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PrimeNumber], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PrimeStream_ServiceDesc.Streams[0], PrimeStream_GetPrimes_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PrimeCount, PrimeNumber]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PrimeStream_GetPrimesClient = grpc.ServerStreamingClient[PrimeNumber]
+
+// PrimeStreamServer is the server API for PrimeStream service.
+// All implementations must embed UnimplementedPrimeStreamServer
+// for forward compatibility.
+type PrimeStreamServer interface {
+	// Served over HTTP by the REST gateway as GET /v1/primes:stream?number=5,
+	// which returns one JSON object per line as the primes are found.
+	GetPrimes(*PrimeCount, grpc.ServerStreamingServer[PrimeNumber]) error
+	mustEmbedUnimplementedPrimeStreamServer()
+}
+
+// UnimplementedPrimeStreamServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPrimeStreamServer struct{}
+
+func (UnimplementedPrimeStreamServer) GetPrimes(*PrimeCount, grpc.ServerStreamingServer[PrimeNumber]) error {
+	return status.Errorf(codes.Unimplemented, "method GetPrimes not implemented")
+}
+func (UnimplementedPrimeStreamServer) mustEmbedUnimplementedPrimeStreamServer() {}
+func (UnimplementedPrimeStreamServer) testEmbeddedByValue()                     {}
+
+// UnsafePrimeStreamServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PrimeStreamServer will
+// result in compilation errors.
+type UnsafePrimeStreamServer interface {
+	mustEmbedUnimplementedPrimeStreamServer()
+}
+
+func RegisterPrimeStreamServer(s grpc.ServiceRegistrar, srv PrimeStreamServer) {
+	// If the following call pancis, it indicates UnimplementedPrimeStreamServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PrimeStream_ServiceDesc, srv)
+}
+
+func _PrimeStream_GetPrimes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PrimeCount)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PrimeStreamServer).GetPrimes(m, &grpc.GenericServerStream[PrimeCount, PrimeNumber]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PrimeStream_GetPrimesServer = grpc.ServerStreamingServer[PrimeNumber]
+
+// PrimeStream_ServiceDesc is the grpc.ServiceDesc for PrimeStream service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PrimeStream_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "apistream.PrimeStream",
+	HandlerType: (*PrimeStreamServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetPrimes",
+			Handler:       _PrimeStream_GetPrimes_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "primestream.proto",
+}
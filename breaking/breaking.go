@@ -0,0 +1,296 @@
+// Package breaking finds changes to the API definitions which would break
+// clients or servers built from an earlier version of them, such as a field
+// given a new number or type, or an RPC removed. The API as it is now, taken
+// from the descriptors compiled into the generated code, is compared with a
+// baseline descriptor set committed alongside this package. Changes which
+// keep the wire format, such as renaming a field or adding a new one, are
+// allowed.
+package breaking
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	// The generated code registers the descriptors of the API.
+	_ "github.com/devries/grpc-tutorial/api"
+	_ "github.com/devries/grpc-tutorial/apistream"
+	_ "github.com/devries/grpc-tutorial/primes/v1"
+)
+
+// Files are the .proto files of the API, by the paths they are registered
+// under.
+var Files = []string{
+	"primes.proto",
+	"primestream.proto",
+	"primes/v1/primes.proto",
+}
+
+// Current returns the descriptors of files as compiled into the generated
+// code.
+func Current(files ...string) (*descriptorpb.FileDescriptorSet, error) {
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, path := range files {
+		fd, err := protoregistry.GlobalFiles.FindFileByPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not find %s: %w", path, err)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	return set, nil
+}
+
+// Compare returns a description of each wire-incompatible change from the
+// old descriptors to the new ones, or nothing if there are none.
+func Compare(old, new *descriptorpb.FileDescriptorSet) []string {
+	oldDefs, newDefs := index(old), index(new)
+	var problems []string
+
+	for name, om := range oldDefs.messages {
+		nm, ok := newDefs.messages[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("message %s was removed", name))
+			continue
+		}
+		problems = append(problems, compareMessages(name, om, nm)...)
+	}
+
+	for name, oe := range oldDefs.enums {
+		ne, ok := newDefs.enums[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("enum %s was removed", name))
+			continue
+		}
+		problems = append(problems, compareEnums(name, oe, ne)...)
+	}
+
+	for name, os := range oldDefs.services {
+		ns, ok := newDefs.services[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("service %s was removed", name))
+			continue
+		}
+		problems = append(problems, compareServices(name, os, ns)...)
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// definitions holds the messages, enums, and services of a descriptor set by
+// their fully qualified names.
+type definitions struct {
+	messages map[string]*descriptorpb.DescriptorProto
+	enums    map[string]*descriptorpb.EnumDescriptorProto
+	services map[string]*descriptorpb.ServiceDescriptorProto
+}
+
+func index(set *descriptorpb.FileDescriptorSet) definitions {
+	d := definitions{
+		messages: make(map[string]*descriptorpb.DescriptorProto),
+		enums:    make(map[string]*descriptorpb.EnumDescriptorProto),
+		services: make(map[string]*descriptorpb.ServiceDescriptorProto),
+	}
+
+	for _, f := range set.GetFile() {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = f.GetPackage() + "."
+		}
+		for _, m := range f.GetMessageType() {
+			d.addMessage(prefix, m)
+		}
+		for _, e := range f.GetEnumType() {
+			d.enums[prefix+e.GetName()] = e
+		}
+		for _, s := range f.GetService() {
+			d.services[prefix+s.GetName()] = s
+		}
+	}
+
+	return d
+}
+
+func (d definitions) addMessage(prefix string, m *descriptorpb.DescriptorProto) {
+	name := prefix + m.GetName()
+	d.messages[name] = m
+	for _, nested := range m.GetNestedType() {
+		d.addMessage(name+".", nested)
+	}
+	for _, e := range m.GetEnumType() {
+		d.enums[name+"."+e.GetName()] = e
+	}
+}
+
+func compareMessages(name string, old, new *descriptorpb.DescriptorProto) []string {
+	var problems []string
+
+	newByNumber := make(map[int32]*descriptorpb.FieldDescriptorProto)
+	newByName := make(map[string]*descriptorpb.FieldDescriptorProto)
+	for _, f := range new.GetField() {
+		newByNumber[f.GetNumber()] = f
+		newByName[f.GetName()] = f
+	}
+
+	for _, of := range old.GetField() {
+		field := name + "." + of.GetName()
+
+		// A field which kept its name but not its number was renumbered,
+		// even if another field has taken its old number, as when two
+		// fields swap numbers.
+		if moved, ok := newByName[of.GetName()]; ok && moved.GetNumber() != of.GetNumber() {
+			problems = append(problems, fmt.Sprintf("field %s was renumbered from %d to %d", field, of.GetNumber(), moved.GetNumber()))
+			continue
+		}
+
+		nf, ok := newByNumber[of.GetNumber()]
+		if !ok {
+			if !reserved(new, of.GetNumber()) {
+				problems = append(problems, fmt.Sprintf("field %s (%d) was removed without reserving its number", field, of.GetNumber()))
+			}
+			continue
+		}
+
+		if oldType, newType := fieldType(of), fieldType(nf); !wireCompatible(oldType, newType) {
+			problems = append(problems, fmt.Sprintf("field %s (%d) changed type from %s to %s", field, of.GetNumber(), oldType, newType))
+		}
+		if repeated(of) != repeated(nf) {
+			problems = append(problems, fmt.Sprintf("field %s (%d) changed from %s to %s", field, of.GetNumber(), label(of), label(nf)))
+		}
+		if inOneof(of) != inOneof(nf) {
+			problems = append(problems, fmt.Sprintf("field %s (%d) moved into or out of a oneof", field, of.GetNumber()))
+		}
+	}
+
+	return problems
+}
+
+func compareEnums(name string, old, new *descriptorpb.EnumDescriptorProto) []string {
+	var problems []string
+
+	numbers := make(map[int32]bool)
+	for _, v := range new.GetValue() {
+		numbers[v.GetNumber()] = true
+	}
+	for _, v := range old.GetValue() {
+		if !numbers[v.GetNumber()] {
+			problems = append(problems, fmt.Sprintf("enum value %s.%s (%d) was removed", name, v.GetName(), v.GetNumber()))
+		}
+	}
+
+	return problems
+}
+
+func compareServices(name string, old, new *descriptorpb.ServiceDescriptorProto) []string {
+	var problems []string
+
+	methods := make(map[string]*descriptorpb.MethodDescriptorProto)
+	for _, m := range new.GetMethod() {
+		methods[m.GetName()] = m
+	}
+
+	for _, om := range old.GetMethod() {
+		method := name + "." + om.GetName()
+		nm, ok := methods[om.GetName()]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("RPC %s was removed", method))
+			continue
+		}
+		if om.GetInputType() != nm.GetInputType() {
+			problems = append(problems, fmt.Sprintf("RPC %s changed its request from %s to %s", method, typeName(om.GetInputType()), typeName(nm.GetInputType())))
+		}
+		if om.GetOutputType() != nm.GetOutputType() {
+			problems = append(problems, fmt.Sprintf("RPC %s changed its response from %s to %s", method, typeName(om.GetOutputType()), typeName(nm.GetOutputType())))
+		}
+		if om.GetClientStreaming() != nm.GetClientStreaming() || om.GetServerStreaming() != nm.GetServerStreaming() {
+			problems = append(problems, fmt.Sprintf("RPC %s changed from %s to %s", method, streaming(om), streaming(nm)))
+		}
+	}
+
+	return problems
+}
+
+func reserved(m *descriptorpb.DescriptorProto, number int32) bool {
+	for _, r := range m.GetReservedRange() {
+		// Reserved ranges exclude their end.
+		if number >= r.GetStart() && number < r.GetEnd() {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldType returns the type of f as written in a .proto file.
+func fieldType(f *descriptorpb.FieldDescriptorProto) string {
+	switch f.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		return typeName(f.GetTypeName())
+	default:
+		return strings.ToLower(strings.TrimPrefix(f.GetType().String(), "TYPE_"))
+	}
+}
+
+func typeName(name string) string {
+	return strings.TrimPrefix(name, ".")
+}
+
+// compatible lists the groups of scalar types which are encoded the same
+// way, so that a field may change between types in the same group.
+var compatible = [][]string{
+	{"int32", "uint32", "int64", "uint64", "bool"},
+	{"sint32", "sint64"},
+	{"fixed32", "sfixed32"},
+	{"fixed64", "sfixed64"},
+	{"string", "bytes"},
+}
+
+func wireCompatible(old, new string) bool {
+	if old == new {
+		return true
+	}
+	for _, group := range compatible {
+		var hasOld, hasNew bool
+		for _, t := range group {
+			hasOld = hasOld || t == old
+			hasNew = hasNew || t == new
+		}
+		if hasOld && hasNew {
+			return true
+		}
+	}
+	return false
+}
+
+func repeated(f *descriptorpb.FieldDescriptorProto) bool {
+	return f.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+}
+
+func label(f *descriptorpb.FieldDescriptorProto) string {
+	if repeated(f) {
+		return "repeated"
+	}
+	return "singular"
+}
+
+// inOneof reports whether f is in a real oneof, rather than the synthetic
+// oneof of a proto3 optional field.
+func inOneof(f *descriptorpb.FieldDescriptorProto) bool {
+	return f.OneofIndex != nil && !f.GetProto3Optional()
+}
+
+func streaming(m *descriptorpb.MethodDescriptorProto) string {
+	switch {
+	case m.GetClientStreaming() && m.GetServerStreaming():
+		return "bidirectional streaming"
+	case m.GetClientStreaming():
+		return "client streaming"
+	case m.GetServerStreaming():
+		return "server streaming"
+	default:
+		return "unary"
+	}
+}
@@ -0,0 +1,213 @@
+package breaking
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const baselineFile = "baseline.binpb"
+
+var update = flag.Bool("update", false, "replace the baseline with the current API")
+
+func loadBaseline(t *testing.T) *descriptorpb.FileDescriptorSet {
+	t.Helper()
+
+	b, err := os.ReadFile(baselineFile)
+	if err != nil {
+		t.Fatalf("could not read baseline: %s", err)
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		t.Fatalf("could not parse baseline: %s", err)
+	}
+	return set
+}
+
+// TestAPI fails if the API has changed in a way which breaks existing clients
+// or servers. Once a change has been checked and is intended, for example a
+// new field or RPC, record it with
+//
+//	go test ./breaking -update
+func TestAPI(t *testing.T) {
+	current, err := Current(Files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		b, err := proto.MarshalOptions{Deterministic: true}.Marshal(current)
+		if err != nil {
+			t.Fatalf("could not encode descriptors: %s", err)
+		}
+		if err := os.WriteFile(baselineFile, b, 0644); err != nil {
+			t.Fatalf("could not write baseline: %s", err)
+		}
+		return
+	}
+
+	for _, p := range Compare(loadBaseline(t), current) {
+		t.Errorf("breaking change: %s", p)
+	}
+}
+
+// testFile returns the descriptor of a file like api/primes.proto.
+func testFile() *descriptorpb.FileDescriptorProto {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  label.Enum(),
+		}
+	}
+	const (
+		optional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		repeated = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	)
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("primes.proto"),
+		Package: proto.String("api"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("PrimeCount"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("number", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional),
+					field("allow_partial", 2, descriptorpb.FieldDescriptorProto_TYPE_BOOL, optional),
+				},
+			},
+			{
+				Name: proto.String("PrimeNumbers"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("contents", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, repeated),
+					field("truncated", 2, descriptorpb.FieldDescriptorProto_TYPE_BOOL, optional),
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{
+			{
+				Name: proto.String("Primes"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					{
+						Name:       proto.String("GetPrimes"),
+						InputType:  proto.String(".api.PrimeCount"),
+						OutputType: proto.String(".api.PrimeNumbers"),
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(f *descriptorpb.FileDescriptorProto)
+		want   string
+	}{
+		{
+			name:   "renumbered field",
+			change: func(f *descriptorpb.FileDescriptorProto) { f.MessageType[0].Field[0].Number = proto.Int32(3) },
+			want:   "field api.PrimeCount.number was renumbered from 1 to 3",
+		},
+		{
+			name: "swapped field numbers",
+			change: func(f *descriptorpb.FileDescriptorProto) {
+				// Each number keeps its type, so only the names show the swap.
+				fields := f.MessageType[1].Field
+				fields[0].Name, fields[1].Name = fields[1].Name, fields[0].Name
+			},
+			want: "field api.PrimeNumbers.contents was renumbered from 1 to 2",
+		},
+		{
+			name: "changed type",
+			change: func(f *descriptorpb.FileDescriptorProto) {
+				f.MessageType[0].Field[0].Type = descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+			},
+			want: "field api.PrimeCount.number (1) changed type from int64 to string",
+		},
+		{
+			name: "repeated field made singular",
+			change: func(f *descriptorpb.FileDescriptorProto) {
+				f.MessageType[1].Field[0].Label = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+			},
+			want: "field api.PrimeNumbers.contents (1) changed from repeated to singular",
+		},
+		{
+			name:   "removed field",
+			change: func(f *descriptorpb.FileDescriptorProto) { f.MessageType[0].Field = f.MessageType[0].Field[:1] },
+			want:   "field api.PrimeCount.allow_partial (2) was removed without reserving its number",
+		},
+		{
+			name:   "removed RPC",
+			change: func(f *descriptorpb.FileDescriptorProto) { f.Service[0].Method = nil },
+			want:   "RPC api.Primes.GetPrimes was removed",
+		},
+		{
+			name:   "streamed response",
+			change: func(f *descriptorpb.FileDescriptorProto) { f.Service[0].Method[0].ServerStreaming = proto.Bool(true) },
+			want:   "RPC api.Primes.GetPrimes changed from unary to server streaming",
+		},
+		{
+			name: "changed request",
+			change: func(f *descriptorpb.FileDescriptorProto) {
+				f.Service[0].Method[0].InputType = proto.String(".api.PrimeNumbers")
+			},
+			want: "RPC api.Primes.GetPrimes changed its request from api.PrimeCount to api.PrimeNumbers",
+		},
+		{
+			name:   "renamed package",
+			change: func(f *descriptorpb.FileDescriptorProto) { f.Package = proto.String("primes") },
+			want:   "service api.Primes was removed",
+		},
+		{
+			name:   "renamed field",
+			change: func(f *descriptorpb.FileDescriptorProto) { f.MessageType[0].Field[0].Name = proto.String("count") },
+		},
+		{
+			name: "widened type",
+			change: func(f *descriptorpb.FileDescriptorProto) {
+				f.MessageType[0].Field[0].Type = descriptorpb.FieldDescriptorProto_TYPE_UINT64.Enum()
+			},
+		},
+		{
+			name: "removed field with reserved number",
+			change: func(f *descriptorpb.FileDescriptorProto) {
+				m := f.MessageType[0]
+				m.Field = m.Field[:1]
+				m.ReservedRange = append(m.ReservedRange, &descriptorpb.DescriptorProto_ReservedRange{Start: proto.Int32(2), End: proto.Int32(3)})
+			},
+		},
+		{
+			name: "added field",
+			change: func(f *descriptorpb.FileDescriptorProto) {
+				f.MessageType[0].Field = append(f.MessageType[0].Field, &descriptorpb.FieldDescriptorProto{
+					Name:   proto.String("offset"),
+					Number: proto.Int32(3),
+					Type:   descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(),
+					Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				})
+			},
+		},
+	}
+
+	old := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{testFile()}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			changed := proto.Clone(old).(*descriptorpb.FileDescriptorSet)
+			tc.change(changed.File[0])
+
+			got := strings.Join(Compare(old, changed), "\n")
+			if !strings.Contains(got, tc.want) || (tc.want == "" && got != "") {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+// Package certs is a small certificate authority for development and tests.
+// It creates a root certificate and issues certificates signed by it for
+// servers and clients, with the host names and IP addresses they are known
+// by, and reads and writes them as PEM files in the layout the servers and
+// clients expect:
+//
+//	minica.pem           the root certificate
+//	minica-key.pem       the key of the root certificate
+//	localhost/cert.pem   a certificate, in a directory named after its
+//	localhost/key.pem    first host name or IP address, and its key
+//	crl.pem              the list of certificates revoked by the root
+//
+// Apart from the revocation list, this is the layout written by minica
+// (https://github.com/jsha/minica).
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Names of the files of the root certificate and its key.
+const (
+	RootCertFile = "minica.pem"
+	RootKeyFile  = "minica-key.pem"
+)
+
+// CRLFile is the name of the file of the certificate revocation list.
+const CRLFile = "crl.pem"
+
+// Names of the files of an issued certificate and its key, within the
+// directory of the certificate.
+const (
+	CertFile = "cert.pem"
+	KeyFile  = "key.pem"
+)
+
+// Authority is a certificate authority.
+type Authority struct {
+	Certificate *x509.Certificate
+	Key         crypto.Signer
+}
+
+// NewAuthority returns a new certificate authority whose root certificate
+// has the given common name and is valid for validity.
+func NewAuthority(commonName string, validity time.Duration) (*Authority, error) {
+	key, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("could not create root certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Authority{Certificate: cert, Key: key}, nil
+}
+
+// LoadAuthority reads a certificate authority from the root certificate and
+// key files in dir.
+func LoadAuthority(dir string) (*Authority, error) {
+	pair, err := tls.LoadX509KeyPair(filepath.Join(dir, RootCertFile), filepath.Join(dir, RootKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok || !cert.IsCA {
+		return nil, fmt.Errorf("%s is not a certificate authority", filepath.Join(dir, RootCertFile))
+	}
+
+	return &Authority{Certificate: cert, Key: key}, nil
+}
+
+// Save writes the root certificate and its key to dir.
+func (a *Authority) Save(dir string) error {
+	return writePair(filepath.Join(dir, RootCertFile), filepath.Join(dir, RootKeyFile), a.Certificate.Raw, a.Key)
+}
+
+// Pool returns a pool holding the root certificate, for verifying the
+// certificates the authority issues.
+func (a *Authority) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(a.Certificate)
+	return pool
+}
+
+// Issue returns a certificate with the given common name, valid for
+// validity, for names, which are host names or IP addresses. If commonName
+// is empty the first name is used. The certificate may be used by both
+// servers and clients, since the servers use their own certificate as a
+// client when forwarding HTTP requests to their gRPC port.
+func (a *Authority) Issue(commonName string, names []string, validity time.Duration) (tls.Certificate, error) {
+	if len(names) == 0 {
+		return tls.Certificate{}, errors.New("a certificate needs at least one host name or IP address")
+	}
+	if commonName == "" {
+		commonName = names[0]
+	}
+
+	key, err := newKey()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, name)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.Certificate, key.Public(), a.Key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("could not issue certificate for %s: %w", names[0], err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// RevocationList returns a certificate revocation list signed by a, in DER
+// form, listing revoked and valid for validity. Each list is numbered with the
+// time it was made, so later lists replace earlier ones.
+func (a *Authority) RevocationList(revoked []x509.RevocationListEntry, validity time.Duration) ([]byte, error) {
+	now := time.Now()
+	template := &x509.RevocationList{
+		RevokedCertificateEntries: revoked,
+		Number:                    big.NewInt(now.UnixNano()),
+		ThisUpdate:                now.Add(-time.Minute),
+		NextUpdate:                now.Add(validity),
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, a.Certificate, a.Key)
+	if err != nil {
+		return nil, fmt.Errorf("could not create revocation list: %w", err)
+	}
+	return der, nil
+}
+
+// SaveRevocationList writes the revocation list der to path in PEM form.
+func SaveRevocationList(path string, der []byte) error {
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0644)
+}
+
+// ParseRevocationList parses a certificate revocation list in PEM or DER
+// form. It does not check the signature of the list.
+func ParseRevocationList(bs []byte) (*x509.RevocationList, error) {
+	if block, _ := pem.Decode(bs); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, fmt.Errorf("found %s where a revocation list was expected", block.Type)
+		}
+		bs = block.Bytes
+	}
+	return x509.ParseRevocationList(bs)
+}
+
+// ParseCertificates parses the certificates in PEM data, such as the
+// contents of minica.pem.
+func ParseCertificates(bs []byte) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, bs = pem.Decode(bs)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, cert)
+	}
+
+	if len(certificates) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+	return certificates, nil
+}
+
+// Save writes cert and its key to the files cert.pem and key.pem in dir,
+// creating dir if needed.
+func Save(dir string, cert tls.Certificate) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	key, ok := cert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return errors.New("unsupported private key")
+	}
+	return writePair(filepath.Join(dir, CertFile), filepath.Join(dir, KeyFile), cert.Certificate[0], key)
+}
+
+func writePair(certFile, keyFile string, der []byte, key crypto.Signer) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	// Keys are only readable by their owner.
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+func newKey() (crypto.Signer, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
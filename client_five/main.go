@@ -3,16 +3,20 @@ package main
 import (
 	"context"
 	"flag"
-	"io/ioutil"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/devries/grpc-tutorial/api"
+	"github.com/devries/grpc-tutorial/clientcreds"
+	"github.com/devries/grpc-tutorial/oauth"
+	"github.com/devries/grpc-tutorial/output"
+	"github.com/devries/grpc-tutorial/retry"
+	"github.com/devries/grpc-tutorial/richstatus"
+	"github.com/devries/grpc-tutorial/tracing"
 	"google.golang.org/grpc"
-
-	"crypto/x509"
 	"google.golang.org/grpc/credentials"
 )
 
@@ -22,35 +26,77 @@ const (
 
 func main() {
 	nf := flag.Int64("n", 5, "number of primes to get")
+	of := flag.String("output", string(output.Log), output.Usage)
+	timeout := flag.Duration("timeout", 5*time.Second, "deadline for the call, including retries")
+	partial := flag.Bool("partial", false, "accept the primes found before the deadline instead of failing")
+	policy := retry.Flags()
+	tlsOpts := clientcreds.Flags(clientcreds.Options{CA: "minica.pem," + clientcreds.Embedded})
+	tokenURL := flag.String("token-url", "", "OAuth 2.0 token endpoint to get access tokens from with the client credentials grant, using the secret in OAUTH_CLIENT_SECRET (the fixed token HelloWorld is sent if empty)")
+	clientID := flag.String("client-id", "client_five", "OAuth 2.0 client ID")
+	scope := flag.String("scope", "", "space separated scopes to request for the access token")
 
 	flag.Parse()
 
-	pool := x509.NewCertPool()
-	bs, err := ioutil.ReadFile("minica.pem")
+	format, err := output.ParseFormat(*of)
 	if err != nil {
-		log.Fatalf("Unable to load ca certificate: %s", err)
+		log.Fatal(err)
 	}
 
-	ok := pool.AppendCertsFromPEM(bs)
-	if !ok {
-		log.Fatal("failed to append ca certificate to pool")
+	if err := policy.Validate(); err != nil {
+		log.Fatal(err)
 	}
 
-	token := TokenAccess{Token: "HelloWorld"}
+	shutdownTracing, err := tracing.Setup(context.Background(), "client_five")
+	if err != nil {
+		log.Fatalf("could not set up tracing: %s", err)
+	}
+	defer shutdownTracing(context.Background())
 
-	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(pool, "")), grpc.WithPerRPCCredentials(token))
+	transportCreds, err := tlsOpts.TransportCredentials()
+	if err != nil {
+		log.Fatalf("Unable to set up TLS: %s", err)
+	}
+
+	var token credentials.PerRPCCredentials = TokenAccess{Token: "HelloWorld"}
+	if *tokenURL != "" {
+		// Access tokens are fetched when first needed and reused until
+		// they are about to expire.
+		token = &oauth.ClientCredentials{
+			TokenURL:     *tokenURL,
+			ClientID:     *clientID,
+			ClientSecret: os.Getenv("OAUTH_CLIENT_SECRET"),
+			Scopes:       strings.Fields(*scope),
+		}
+	}
+
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(transportCreds), grpc.WithPerRPCCredentials(token)}
+	dialOpts = append(dialOpts, policy.DialOptions("api.Primes")...)
+	dialOpts = append(dialOpts, tracing.DialOptions()...)
+
+	conn, err := grpc.Dial(address, dialOpts...)
 	if err != nil {
 		log.Fatalf("did not connect: %s", err)
 	}
 	defer conn.Close()
 
 	c := api.NewPrimesClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
 	defer cancel()
 
-	r, err := c.GetPrimes(ctx, &api.PrimeCount{Number: *nf})
+	r, err := c.GetPrimes(ctx, &api.PrimeCount{Number: *nf, AllowPartial: *partial})
 	if err != nil {
-		log.Fatalf("could not get primes: %s", err)
+		log.Fatalf("could not get primes: %s", richstatus.Format(err))
+	}
+
+	if r.Truncated {
+		log.Printf("Deadline reached, received %d of %d primes", len(r.Contents), *nf)
+	}
+
+	if format != output.Log {
+		if err := output.WriteAll(os.Stdout, format, r.Contents); err != nil {
+			log.Fatalf("could not write primes: %s", err)
+		}
+		return
 	}
 
 	primeStrings := make([]string, 0, *nf)
@@ -58,7 +104,7 @@ func main() {
 		primeStrings = append(primeStrings, strconv.FormatInt(p, 10))
 	}
 
-	log.Printf("First %d primes: %s", *nf, strings.Join(primeStrings, ", "))
+	log.Printf("First %d primes: %s", len(r.Contents), strings.Join(primeStrings, ", "))
 }
 
 type TokenAccess struct {
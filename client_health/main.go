@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/devries/grpc-tutorial/clientcreds"
+)
+
+// This client queries the standard gRPC health service on any of the servers
+// and exits with a non-zero status unless the service is serving, so it can
+// be used as a probe.
+func main() {
+	host := flag.String("h", "localhost", "host name")
+	port := flag.Int("p", 50051, "port number")
+	service := flag.String("service", "", "service to check, such as primes.v1.PrimesService or api.Primes (empty for the whole server)")
+	tlsOpts := clientcreds.Flags(clientcreds.Options{})
+	timeout := flag.Duration("timeout", 5*time.Second, "deadline for the health check")
+
+	flag.Parse()
+
+	// Without -ca the server is checked in plaintext.
+	creds := insecure.NewCredentials()
+	if tlsOpts.CA != "" {
+		var err error
+		creds, err = tlsOpts.TransportCredentials()
+		if err != nil {
+			log.Fatalf("Unable to set up TLS: %s", err)
+		}
+	}
+
+	address := fmt.Sprintf("%s:%d", *host, *port)
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		log.Fatalf("did not connect: %s", err)
+	}
+	defer conn.Close()
+
+	c := healthpb.NewHealthClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	r, err := c.Check(ctx, &healthpb.HealthCheckRequest{Service: *service})
+	if err != nil {
+		log.Fatalf("health check failed: %s", err)
+	}
+
+	log.Printf("Status: %s", r.Status)
+	if r.Status != healthpb.HealthCheckResponse_SERVING {
+		os.Exit(1)
+	}
+}
@@ -4,11 +4,16 @@ import (
 	"context"
 	"flag"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/devries/grpc-tutorial/api"
+	"github.com/devries/grpc-tutorial/output"
+	"github.com/devries/grpc-tutorial/retry"
+	"github.com/devries/grpc-tutorial/richstatus"
+	"github.com/devries/grpc-tutorial/tracing"
 	"google.golang.org/grpc"
 )
 
@@ -18,22 +23,56 @@ const (
 
 func main() {
 	nf := flag.Int64("n", 5, "number of primes to get")
+	of := flag.String("output", string(output.Log), output.Usage)
+	timeout := flag.Duration("timeout", 5*time.Second, "deadline for the call, including retries")
+	partial := flag.Bool("partial", false, "accept the primes found before the deadline instead of failing")
+	policy := retry.Flags()
 
 	flag.Parse()
 
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	format, err := output.ParseFormat(*of)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := policy.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "client_one")
+	if err != nil {
+		log.Fatalf("could not set up tracing: %s", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	dialOpts := []grpc.DialOption{grpc.WithInsecure()}
+	dialOpts = append(dialOpts, policy.DialOptions("api.Primes")...)
+	dialOpts = append(dialOpts, tracing.DialOptions()...)
+
+	conn, err := grpc.Dial(address, dialOpts...)
 	if err != nil {
 		log.Fatalf("did not connect: %s", err)
 	}
 	defer conn.Close()
 
 	c := api.NewPrimesClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
 	defer cancel()
 
-	r, err := c.GetPrimes(ctx, &api.PrimeCount{Number: *nf})
+	r, err := c.GetPrimes(ctx, &api.PrimeCount{Number: *nf, AllowPartial: *partial})
 	if err != nil {
-		log.Fatalf("could not get primes: %s", err)
+		log.Fatalf("could not get primes: %s", richstatus.Format(err))
+	}
+
+	if r.Truncated {
+		log.Printf("Deadline reached, received %d of %d primes", len(r.Contents), *nf)
+	}
+
+	if format != output.Log {
+		if err := output.WriteAll(os.Stdout, format, r.Contents); err != nil {
+			log.Fatalf("could not write primes: %s", err)
+		}
+		return
 	}
 
 	primeStrings := make([]string, 0, *nf)
@@ -41,5 +80,5 @@ func main() {
 		primeStrings = append(primeStrings, strconv.FormatInt(p, 10))
 	}
 
-	log.Printf("First %d primes: %s", *nf, strings.Join(primeStrings, ", "))
+	log.Printf("First %d primes: %s", len(r.Contents), strings.Join(primeStrings, ", "))
 }
@@ -8,38 +8,52 @@ import (
 	"log"
 	"os"
 
-	_ "embed"
-
 	"github.com/devries/grpc-tutorial/apistream"
+	"github.com/devries/grpc-tutorial/clientcreds"
+	"github.com/devries/grpc-tutorial/output"
+	"github.com/devries/grpc-tutorial/quota"
+	"github.com/devries/grpc-tutorial/retry"
+	"github.com/devries/grpc-tutorial/richstatus"
+	"github.com/devries/grpc-tutorial/tracing"
 	"google.golang.org/grpc"
-
-	"crypto/x509"
-	"google.golang.org/grpc/credentials"
 )
 
-//go:embed minica.pem
-var bs []byte
-
 func main() {
 	nf := flag.Int64("n", 5, "number of primes to get")
 	host := flag.String("h", "localhost", "host name")
 	port := flag.Int("p", 55551, "port number")
+	of := flag.String("output", string(output.Log), output.Usage)
+	policy := retry.Flags()
+	tlsOpts := clientcreds.Flags(clientcreds.Options{CA: "minica.pem," + clientcreds.Embedded})
 
 	flag.Parse()
 
-	pool := x509.NewCertPool()
-	bs, err := os.ReadFile("minica.pem")
+	format, err := output.ParseFormat(*of)
 	if err != nil {
-		log.Fatalf("Unable to load ca certificate: %s", err)
+		log.Fatal(err)
 	}
 
-	ok := pool.AppendCertsFromPEM(bs)
-	if !ok {
-		log.Fatal("failed to append ca certificate to pool")
+	if err := policy.ValidateStream(); err != nil {
+		log.Fatal(err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "client_stream")
+	if err != nil {
+		log.Fatalf("could not set up tracing: %s", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	transportCreds, err := tlsOpts.TransportCredentials()
+	if err != nil {
+		log.Fatalf("Unable to set up TLS: %s", err)
 	}
 
 	address := fmt.Sprintf("%s:%d", *host, *port)
-	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(pool, "")))
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(transportCreds)}
+	dialOpts = append(dialOpts, policy.DialOptions("apistream.PrimeStream")...)
+	dialOpts = append(dialOpts, tracing.DialOptions()...)
+
+	conn, err := grpc.Dial(address, dialOpts...)
 	if err != nil {
 		log.Fatalf("did not connect: %s", err)
 	}
@@ -62,7 +76,15 @@ func main() {
 
 	stream, err := c.GetPrimes(ctx, &apistream.PrimeCount{Number: *nf})
 	if err != nil {
-		log.Fatalf("could not get primes: %s", err)
+		log.Fatalf("could not get primes: %s", richstatus.Format(err))
+	}
+
+	var w *output.Writer
+	if format != output.Log {
+		w, err = output.NewWriter(os.Stdout, format)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	for {
@@ -71,11 +93,31 @@ func main() {
 			break
 		}
 		if err != nil {
-			log.Fatalf("error: %s", err)
+			log.Fatalf("error: %s", richstatus.Format(err))
+		}
+
+		if w != nil {
+			if err := w.Write(res.GetCount(), res.GetValue()); err != nil {
+				log.Fatalf("could not write prime: %s", err)
+			}
+			continue
 		}
 
 		if res.GetCount()%displaydivisor == 0 {
 			log.Printf("Received prime %7d: %8d", res.GetCount(), res.GetValue())
 		}
 	}
+
+	if w != nil {
+		if err := w.Close(); err != nil {
+			log.Fatalf("could not write primes: %s", err)
+		}
+	}
+
+	// Servers with quotas report what is left of them in the trailer.
+	for _, k := range []string{quota.RemainingHourlyKey, quota.RemainingDailyKey} {
+		if v := stream.Trailer().Get(k); len(v) > 0 {
+			log.Printf("Quota remaining (%s): %s", k, v[0])
+		}
+	}
 }
@@ -4,15 +4,18 @@ import (
 	"context"
 	"flag"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/devries/grpc-tutorial/api"
+	"github.com/devries/grpc-tutorial/clientcreds"
+	"github.com/devries/grpc-tutorial/output"
+	"github.com/devries/grpc-tutorial/retry"
+	"github.com/devries/grpc-tutorial/richstatus"
+	"github.com/devries/grpc-tutorial/tracing"
 	"google.golang.org/grpc"
-
-	"crypto/x509"
-	"google.golang.org/grpc/credentials"
 )
 
 const (
@@ -21,27 +24,62 @@ const (
 
 func main() {
 	nf := flag.Int64("n", 5, "number of primes to get")
+	of := flag.String("output", string(output.Log), output.Usage)
+	timeout := flag.Duration("timeout", 5*time.Second, "deadline for the call, including retries")
+	partial := flag.Bool("partial", false, "accept the primes found before the deadline instead of failing")
+	policy := retry.Flags()
+	tlsOpts := clientcreds.Flags(clientcreds.Options{CA: clientcreds.System})
 
 	flag.Parse()
 
-	pool, err := x509.SystemCertPool()
+	format, err := output.ParseFormat(*of)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := policy.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "client_two")
+	if err != nil {
+		log.Fatalf("could not set up tracing: %s", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	transportCreds, err := tlsOpts.TransportCredentials()
 	if err != nil {
-		log.Fatalf("Unable to load certificate pool: %s", err)
+		log.Fatalf("Unable to set up TLS: %s", err)
 	}
 
-	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(pool, "")))
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(transportCreds)}
+	dialOpts = append(dialOpts, policy.DialOptions("api.Primes")...)
+	dialOpts = append(dialOpts, tracing.DialOptions()...)
+
+	conn, err := grpc.Dial(address, dialOpts...)
 	if err != nil {
 		log.Fatalf("did not connect: %s", err)
 	}
 	defer conn.Close()
 
 	c := api.NewPrimesClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
 	defer cancel()
 
-	r, err := c.GetPrimes(ctx, &api.PrimeCount{Number: *nf})
+	r, err := c.GetPrimes(ctx, &api.PrimeCount{Number: *nf, AllowPartial: *partial})
 	if err != nil {
-		log.Fatalf("could not get primes: %s", err)
+		log.Fatalf("could not get primes: %s", richstatus.Format(err))
+	}
+
+	if r.Truncated {
+		log.Printf("Deadline reached, received %d of %d primes", len(r.Contents), *nf)
+	}
+
+	if format != output.Log {
+		if err := output.WriteAll(os.Stdout, format, r.Contents); err != nil {
+			log.Fatalf("could not write primes: %s", err)
+		}
+		return
 	}
 
 	primeStrings := make([]string, 0, *nf)
@@ -49,5 +87,5 @@ func main() {
 		primeStrings = append(primeStrings, strconv.FormatInt(p, 10))
 	}
 
-	log.Printf("First %d primes: %s", *nf, strings.Join(primeStrings, ", "))
+	log.Printf("First %d primes: %s", len(r.Contents), strings.Join(primeStrings, ", "))
 }
@@ -0,0 +1,284 @@
+// Package clientcreds builds the TLS credentials of the Go clients from
+// command line flags: which root certificates they trust, the certificate
+// they present to servers which ask for one, and the public keys or
+// certificates they expect the server to have.
+//
+// Root certificates can come from a PEM file such as minica.pem, from the
+// certificates built into the client (the embedded source, see the ca
+// directory), or from the system pool. Several sources may be given, and the
+// first which provides certificates is used, so a client can prefer a local
+// minica.pem and fall back to its built-in certificates. If none works the
+// error explains what went wrong with each.
+package clientcreds
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"embed"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// Sources of root certificates other than files.
+const (
+	// Embedded is the root certificates built into the client from the
+	// *.pem files in the ca directory of this package.
+	Embedded = "embedded"
+	// System is the system's pool of root certificates.
+	System = "system"
+)
+
+//go:embed ca
+var embedded embed.FS
+
+// Options describes the credentials of a client.
+type Options struct {
+	// CA is a comma separated list of sources of root certificates, tried in
+	// order: paths of PEM files, Embedded, or System.
+	CA string
+	// CertFile and KeyFile hold the client certificate and its key, if the
+	// client presents one.
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name the server certificate is checked
+	// against, which is otherwise the host the client connects to.
+	ServerName string
+	// Pin is a comma separated list of pins, each either the SHA-256 hash
+	// of a public key as "sha256/<base64>" (see SPKIPin), or the SHA-256
+	// fingerprint of a certificate as "cert-sha256/<hex>" (see CertPin). If
+	// set, the server is only accepted if its certificate, or one of the
+	// certificates verifying it, matches one of the pins. Giving both the
+	// old and new pins lets a key or certificate be replaced without
+	// breaking clients.
+	Pin string
+}
+
+// Flags registers command line flags for the credentials, starting from
+// defaults, and returns the options they set.
+func Flags(defaults Options) *Options {
+	o := defaults
+
+	flag.StringVar(&o.CA, "ca", o.CA, `comma separated root certificate sources, tried in order: PEM files, "embedded" for those built into the client, or "system"`)
+	flag.StringVar(&o.CertFile, "cert", o.CertFile, "client certificate, for servers which require one")
+	flag.StringVar(&o.KeyFile, "key", o.KeyFile, "key of the client certificate")
+	flag.StringVar(&o.ServerName, "server-name", o.ServerName, "name to check the server certificate against (the host name if empty)")
+	flag.StringVar(&o.Pin, "pin", o.Pin, "comma separated pins of the server's public key, as sha256/<base64>, or certificate, as cert-sha256/<hex>")
+
+	return &o
+}
+
+// TLSConfig returns the TLS configuration described by o.
+func (o *Options) TLSConfig() (*tls.Config, error) {
+	pool, err := RootCAs(o.CA)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{RootCAs: pool, ServerName: o.ServerName}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	if o.Pin != "" {
+		verify, err := verifyPins(o.Pin)
+		if err != nil {
+			return nil, err
+		}
+		config.VerifyPeerCertificate = verify
+	}
+
+	return config, nil
+}
+
+// TransportCredentials returns the gRPC credentials described by o.
+func (o *Options) TransportCredentials() (credentials.TransportCredentials, error) {
+	config, err := o.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// RootCAs returns the root certificates of the first of the comma separated
+// sources which provides any.
+func RootCAs(sources string) (*x509.CertPool, error) {
+	var problems []string
+	for _, source := range strings.Split(sources, ",") {
+		source = strings.TrimSpace(source)
+		if source == "" {
+			continue
+		}
+
+		pool, err := rootCAs(source)
+		if err == nil {
+			return pool, nil
+		}
+		problems = append(problems, fmt.Sprintf("%s: %s", source, err))
+	}
+
+	if len(problems) == 0 {
+		return nil, errors.New("no root certificate sources given")
+	}
+	return nil, fmt.Errorf("no usable root certificates: %s", strings.Join(problems, "; "))
+}
+
+func rootCAs(source string) (*x509.CertPool, error) {
+	switch source {
+	case System:
+		return x509.SystemCertPool()
+	case Embedded:
+		return embeddedRootCAs()
+	}
+
+	bs, err := os.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bs) {
+		return nil, errors.New("no certificates in file")
+	}
+	return pool, nil
+}
+
+func embeddedRootCAs() (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	found := false
+
+	err := fs.WalkDir(embedded, "ca", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(name) != ".pem" {
+			return err
+		}
+		bs, err := embedded.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if pool.AppendCertsFromPEM(bs) {
+			found = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, errors.New("no certificates were built into this client, see clientcreds/ca")
+	}
+	return pool, nil
+}
+
+// Prefixes of the two kinds of pin.
+const (
+	spkiPrefix = "sha256/"
+	certPrefix = "cert-sha256/"
+)
+
+// SPKIPin returns the pin of the public key of cert, as "sha256/<base64>".
+// It is the same as the output of
+//
+//	openssl x509 -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return spkiPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// CertPin returns the pin of cert itself, as "cert-sha256/<hex>". The hex
+// digits may also be written in upper case separated by colons, as printed
+// by
+//
+//	openssl x509 -noout -fingerprint -sha256
+func CertPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return certPrefix + hex.EncodeToString(sum[:])
+}
+
+// pin is a parsed pin: the SHA-256 hash of either a certificate's public key
+// or the whole certificate.
+type pin struct {
+	cert bool
+	sum  [sha256.Size]byte
+}
+
+func (p pin) matches(cert *x509.Certificate) bool {
+	if p.cert {
+		return sha256.Sum256(cert.Raw) == p.sum
+	}
+	return sha256.Sum256(cert.RawSubjectPublicKeyInfo) == p.sum
+}
+
+func parsePin(s string) (pin, error) {
+	var (
+		p   pin
+		sum []byte
+		err error
+	)
+	if encoded, ok := strings.CutPrefix(s, spkiPrefix); ok {
+		sum, err = base64.StdEncoding.DecodeString(encoded)
+	} else if encoded, ok := strings.CutPrefix(s, certPrefix); ok {
+		p.cert = true
+		sum, err = hex.DecodeString(strings.ReplaceAll(encoded, ":", ""))
+	} else {
+		return p, fmt.Errorf("pin %q must start with %s or %s", s, spkiPrefix, certPrefix)
+	}
+	if err != nil || len(sum) != sha256.Size {
+		return p, fmt.Errorf("pin %q is not a SHA-256 hash", s)
+	}
+
+	copy(p.sum[:], sum)
+	return p, nil
+}
+
+// verifyPins returns a function for tls.Config.VerifyPeerCertificate which
+// accepts a server only if one of its verified certificates matches one of
+// the comma separated pins. It runs after the usual verification of the
+// certificates, so pinning a certificate authority only accepts servers with
+// valid certificates it issued.
+func verifyPins(list string) (func([][]byte, [][]*x509.Certificate) error, error) {
+	var pins []pin
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		p, err := parsePin(s)
+		if err != nil {
+			return nil, err
+		}
+		pins = append(pins, p)
+	}
+	if len(pins) == 0 {
+		return nil, errors.New("no pins given")
+	}
+
+	return func(_ [][]byte, chains [][]*x509.Certificate) error {
+		if len(chains) == 0 {
+			return errors.New("server certificate was not verified, so cannot be checked against pins")
+		}
+		for _, chain := range chains {
+			for _, cert := range chain {
+				for _, p := range pins {
+					if p.matches(cert) {
+						return nil
+					}
+				}
+			}
+		}
+		return fmt.Errorf("server certificate %q does not match any pin", chains[0][0].Subject.CommonName)
+	}, nil
+}
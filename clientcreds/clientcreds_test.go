@@ -0,0 +1,116 @@
+package clientcreds
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devries/grpc-tutorial/certs"
+)
+
+func TestRootCAs(t *testing.T) {
+	authority, err := certs.NewAuthority("clientcreds test ca", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := authority.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(dir, certs.RootCertFile)
+	missing := filepath.Join(dir, "missing.pem")
+
+	t.Run("falls back", func(t *testing.T) {
+		pool, err := RootCAs(missing + "," + caFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pool.Equal(authority.Pool()) {
+			t.Error("pool does not hold the CA certificate")
+		}
+	})
+
+	t.Run("none usable", func(t *testing.T) {
+		_, err := RootCAs(missing + ", " + filepath.Join(dir, certs.RootKeyFile))
+		if err == nil {
+			t.Fatal("no error")
+		}
+		for _, want := range []string{"missing.pem: open", "minica-key.pem: no certificates in file"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not mention %q", err, want)
+			}
+		}
+	})
+
+	t.Run("no sources", func(t *testing.T) {
+		if _, err := RootCAs(""); err == nil {
+			t.Error("no error")
+		}
+	})
+}
+
+func TestPins(t *testing.T) {
+	authority, err := certs.NewAuthority("clientcreds test ca", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := authority.Issue("", []string{"localhost"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := certs.NewAuthority("other ca", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chains := [][]*x509.Certificate{{server.Leaf, authority.Certificate}}
+
+	// The fingerprint as printed by openssl x509 -fingerprint.
+	sum := sha256.Sum256(server.Leaf.Raw)
+	var openssl []string
+	for _, b := range sum {
+		openssl = append(openssl, fmt.Sprintf("%02X", b))
+	}
+
+	tests := []struct {
+		name string
+		pins string
+		ok   bool
+	}{
+		{"server key", SPKIPin(server.Leaf), true},
+		{"CA key", SPKIPin(authority.Certificate), true},
+		{"server certificate", CertPin(server.Leaf), true},
+		{"openssl fingerprint", "cert-sha256/" + strings.Join(openssl, ":"), true},
+		{"other key", SPKIPin(other.Certificate), false},
+		{"other certificate", CertPin(other.Certificate), false},
+		{"rotation", SPKIPin(other.Certificate) + ", " + SPKIPin(server.Leaf), true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			verify, err := verifyPins(tc.pins)
+			if err != nil {
+				if tc.ok {
+					t.Fatal(err)
+				}
+				return
+			}
+			err = verify(nil, chains)
+			if tc.ok && err != nil {
+				t.Errorf("server rejected: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Error("server accepted")
+			}
+		})
+	}
+
+	for _, pins := range []string{"", "sha1/AAAA", "sha256/not base64", "sha256/AAAA", "cert-sha256/abc", SPKIPin(server.Leaf) + ",bad"} {
+		if _, err := verifyPins(pins); err == nil {
+			t.Errorf("pins %q accepted", pins)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Package deadline helps the servers respect how long a client is willing to
+// wait. An Estimator learns how long finding primes takes, so that a call
+// which cannot possibly finish before its deadline is rejected straight away
+// rather than wasting a generator, and gives clients which accept partial
+// results the primes found before their deadline.
+package deadline
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Margin is the time kept back from the deadline for sending the response.
+const Margin = 20 * time.Millisecond
+
+// DefaultPerPrime is the estimated time to find one prime before any calls
+// have been observed.
+const DefaultPerPrime = 2 * time.Microsecond
+
+// weight is how much each observation moves the estimate.
+const weight = 0.2
+
+// Estimator predicts how long finding a number of primes takes, from a
+// moving average of the time per prime in earlier calls.
+type Estimator struct {
+	mu       sync.Mutex
+	perPrime float64 // nanoseconds
+}
+
+// NewEstimator returns an estimator starting from DefaultPerPrime.
+func NewEstimator() *Estimator {
+	return &Estimator{perPrime: float64(DefaultPerPrime)}
+}
+
+// Estimate returns how long finding n primes is expected to take.
+func (e *Estimator) Estimate(n int64) time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return time.Duration(e.perPrime * float64(n))
+}
+
+// Observe records that finding n primes took d.
+func (e *Estimator) Observe(n int64, d time.Duration) {
+	if n <= 0 {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.perPrime += weight * (float64(d)/float64(n) - e.perPrime)
+}
+
+// Check returns a DeadlineExceeded error if the deadline of ctx is too near
+// to find n primes. Calls without a deadline, and calls accepting partial
+// results, always pass.
+func (e *Estimator) Check(ctx context.Context, n int64, allowPartial bool) error {
+	d, ok := ctx.Deadline()
+	if !ok || allowPartial {
+		return nil
+	}
+
+	remaining := time.Until(d) - Margin
+	if estimate := e.Estimate(n); estimate > remaining {
+		return status.Errorf(codes.DeadlineExceeded, "finding %d primes should take %s but only %s remain before the deadline", n, estimate, max(remaining, 0).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// Cutoff returns a channel which receives when the primes found so far
+// should be returned, Margin before the deadline of ctx, and a function to
+// release its timer. If partial results are not allowed, or ctx has no
+// deadline, the channel is nil and never ready.
+func Cutoff(ctx context.Context, allowPartial bool) (<-chan time.Time, func()) {
+	d, ok := ctx.Deadline()
+	if !ok || !allowPartial {
+		return nil, func() {}
+	}
+
+	t := time.NewTimer(time.Until(d) - Margin)
+	return t.C, func() { t.Stop() }
+}
@@ -0,0 +1,98 @@
+// Package discovery registers the gRPC reflection service on the servers, so
+// tools such as grpcurl can find primes.v1.PrimesService and the older
+// services without a local copy of the proto files. Access to the reflection
+// service can be limited to authenticated clients.
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// Mode controls whether the reflection service is available.
+type Mode int
+
+const (
+	// Open registers the reflection service for every client.
+	Open Mode = iota
+	// Off does not register the reflection service.
+	Off
+	// Authenticated registers the reflection service, but only answers
+	// clients which the server considers authorized.
+	Authenticated
+)
+
+// ModeFromEnv reads the mode from the REFLECTION environment variable, which
+// may be "on" (the default), "off", or "auth".
+func ModeFromEnv() (Mode, error) {
+	switch v := strings.ToLower(os.Getenv("REFLECTION")); v {
+	case "", "on":
+		return Open, nil
+	case "off":
+		return Off, nil
+	case "auth":
+		return Authenticated, nil
+	default:
+		return Open, fmt.Errorf("REFLECTION must be on, off, or auth, not %q", v)
+	}
+}
+
+// ServerOptions returns the server options needed for mode. In the
+// Authenticated mode, authorized decides which clients may use reflection.
+func ServerOptions(mode Mode, authorized func(context.Context) bool) ([]grpc.ServerOption, error) {
+	if mode != Authenticated {
+		return nil, nil
+	}
+	if authorized == nil {
+		return nil, fmt.Errorf("this server has no way to authenticate reflection clients")
+	}
+
+	interceptor := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if IsReflection(info.FullMethod) && !authorized(ss.Context()) {
+			return status.Errorf(codes.Unauthenticated, "reflection requires an authenticated client")
+		}
+		return handler(srv, ss)
+	}
+
+	return []grpc.ServerOption{grpc.ChainStreamInterceptor(interceptor)}, nil
+}
+
+// Register adds the v1 and v1alpha reflection services to s unless mode is
+// Off.
+func Register(s *grpc.Server, mode Mode) {
+	if mode == Off {
+		return
+	}
+	reflection.Register(s)
+}
+
+// IsReflection reports whether fullMethod belongs to a reflection service.
+func IsReflection(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/grpc.reflection.v1.ServerReflection/") ||
+		strings.HasPrefix(fullMethod, "/grpc.reflection.v1alpha.ServerReflection/")
+}
+
+// HasClientCertificate reports whether the client presented a certificate
+// which was verified during the TLS handshake.
+func HasClientCertificate(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	tlsAuth, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return false
+	}
+
+	return len(tlsAuth.State.VerifiedChains) > 0
+}
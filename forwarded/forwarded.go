@@ -0,0 +1,206 @@
+// Package forwarded passes the identity of the clients of the REST gateway
+// and the gRPC-Web and Connect handlers on to the gRPC server. Those HTTP
+// servers forward each call to the server's own gRPC port over loopback, so
+// without this every HTTP client would look to the gRPC server like the HTTP
+// server itself, sharing one rate limit, one quota, and its certificate.
+//
+// The HTTP servers send the client's address and verified certificate chain
+// in metadata, and the gRPC server puts them in place of the loopback peer,
+// so that rate limits, quotas, logs, traces, and checks of client
+// certificates all see the original client. The metadata is trusted only on
+// calls made over loopback by the server's own HTTP servers, which prove who
+// they are with a secret chosen afresh by each process and, on the servers
+// using TLS, with the server's own certificate.
+package forwarded
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys carrying the identity of an HTTP client.
+const (
+	// PeerKey is the client's address, as host:port.
+	PeerKey = "x-forwarded-peer"
+	// CertificateKey holds the DER certificates of the client's verified
+	// chain, leaf first.
+	CertificateKey = "x-forwarded-client-cert-bin"
+	// SecretKey holds the secret of the process, showing that the call was
+	// forwarded by one of its own HTTP servers.
+	SecretKey = "x-forwarded-secret"
+)
+
+// secret is shared by the HTTP servers and the gRPC server of this process,
+// and known to no other.
+var secret = newSecret()
+
+func newSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Reserved reports whether key is one of the metadata keys set by the HTTP
+// servers, which must never be passed on from the HTTP client itself.
+func Reserved(key string) bool {
+	key = strings.ToLower(key)
+	return key == PeerKey || key == CertificateKey || key == SecretKey
+}
+
+// Metadata returns the metadata identifying the client of r.
+func Metadata(r *http.Request) metadata.MD {
+	md := metadata.Pairs(SecretKey, secret, PeerKey, r.RemoteAddr)
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		for _, c := range r.TLS.VerifiedChains[0] {
+			md.Append(CertificateKey, string(c.Raw))
+		}
+	}
+	return md
+}
+
+// Handler adds the metadata identifying the client of each request to the
+// outgoing metadata of the request's context, for handlers which forward
+// calls to the gRPC server with that context.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		md, _ := metadata.FromOutgoingContext(r.Context())
+		ctx := metadata.NewOutgoingContext(r.Context(), metadata.Join(md, Metadata(r)))
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ServerOptions returns the interceptors which replace the peer of calls
+// forwarded by the HTTP servers with the client they were forwarded for.
+// They must come before any interceptor which looks at the peer. proxy is
+// the certificate the HTTP servers present when calling the gRPC server;
+// without TLS it is nil, and callers over loopback are trusted if they send
+// the secret of the process.
+func ServerOptions(proxy *tls.Certificate) []grpc.ServerOption {
+	t := &trust{}
+	if proxy != nil && len(proxy.Certificate) > 0 {
+		t.certificate = proxy.Certificate[0]
+	}
+
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(t.unaryServerInterceptor),
+		grpc.ChainStreamInterceptor(t.streamServerInterceptor),
+	}
+}
+
+// trust decides which callers may forward identities.
+type trust struct {
+	certificate []byte
+}
+
+// trusted reports whether p, which sent md, is one of the server's own HTTP
+// servers.
+func (t *trust) trusted(p *peer.Peer, md metadata.MD) bool {
+	addr, ok := p.Addr.(*net.TCPAddr)
+	if !ok || !addr.IP.IsLoopback() {
+		return false
+	}
+	secrets := md.Get(SecretKey)
+	if len(secrets) != 1 || subtle.ConstantTimeCompare([]byte(secrets[0]), []byte(secret)) != 1 {
+		return false
+	}
+	if t.certificate == nil {
+		return true
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	return ok && len(info.State.VerifiedChains) > 0 && bytes.Equal(info.State.VerifiedChains[0][0].Raw, t.certificate)
+}
+
+// forward returns ctx with its peer replaced by the client the call was
+// forwarded for. Calls which carry no identity, or whose caller is not
+// trusted, keep their own peer. Either way the forwarded metadata is removed,
+// so that the secret goes no further.
+func (t *trust) forward(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	addrs, certs := md.Get(PeerKey), md.Get(CertificateKey)
+	p, ok := peer.FromContext(ctx)
+	trusted := len(addrs) > 0 && ok && t.trusted(p, md)
+
+	if len(addrs) > 0 || len(certs) > 0 || len(md.Get(SecretKey)) > 0 {
+		md = md.Copy()
+		md.Delete(SecretKey)
+		md.Delete(PeerKey)
+		md.Delete(CertificateKey)
+		ctx = metadata.NewIncomingContext(ctx, md)
+	}
+	if !trusted {
+		return ctx, nil
+	}
+
+	addr, err := netip.ParseAddrPort(addrs[0])
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "invalid forwarded peer %q", addrs[0])
+	}
+	client := &peer.Peer{Addr: net.TCPAddrFromAddrPort(addr), LocalAddr: p.LocalAddr}
+
+	// The HTTP server verified the chain against the same CAs as the gRPC
+	// server, so it is passed on as verified.
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		var chain []*x509.Certificate
+		for _, der := range certs {
+			c, err := x509.ParseCertificate([]byte(der))
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "invalid forwarded certificate: %s", err)
+			}
+			chain = append(chain, c)
+		}
+
+		state := tls.ConnectionState{Version: info.State.Version, HandshakeComplete: true}
+		if len(chain) > 0 {
+			state.PeerCertificates = chain
+			state.VerifiedChains = [][]*x509.Certificate{chain}
+		}
+		client.AuthInfo = credentials.TLSInfo{State: state, CommonAuthInfo: info.CommonAuthInfo}
+	}
+
+	return peer.NewContext(ctx, client), nil
+}
+
+func (t *trust) unaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := t.forward(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (t *trust) streamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := t.forward(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &forwardedStream{ServerStream: ss, ctx: ctx})
+}
+
+// forwardedStream is a server stream with the forwarded peer in its context.
+type forwardedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *forwardedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,201 @@
+package forwarded
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/devries/grpc-tutorial/ratelimit"
+	"github.com/devries/grpc-tutorial/servertest"
+)
+
+// serve runs a health server which trusts calls forwarded by proxy over a
+// loopback port, and returns its address and a channel receiving the client
+// key of each call, as the rate limiter would see it.
+func serve(t *testing.T, ca *servertest.CA, proxy *tls.Certificate) (string, <-chan string) {
+	t.Helper()
+
+	tlsConfig := &tls.Config{
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		Certificates: []tls.Certificate{ca.Issue(t, "localhost")},
+		ClientCAs:    ca.Pool,
+	}
+	return serveWith(t, credentials.NewTLS(tlsConfig), proxy)
+}
+
+// serveWith runs the health server with creds.
+func serveWith(t *testing.T, creds credentials.TransportCredentials, proxy *tls.Certificate) (string, <-chan string) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+
+	keys := make(chan string, 1)
+	record := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		// Handlers never see the forwarded metadata, trusted or not.
+		md, _ := metadata.FromIncomingContext(ctx)
+		for k := range md {
+			if Reserved(k) {
+				t.Errorf("handler sees %s", k)
+			}
+		}
+		keys <- ratelimit.ByCertificate(ctx)
+		return handler(ctx, req)
+	}
+
+	opts := append([]grpc.ServerOption{grpc.Creds(creds)}, ServerOptions(proxy)...)
+	opts = append(opts, grpc.ChainUnaryInterceptor(record))
+	s := grpc.NewServer(opts...)
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	return lis.Addr().String(), keys
+}
+
+// forward makes a call to addr with cert, carrying the identity of the client
+// of an HTTP request from remoteAddr which presented clientCerts.
+func forward(t *testing.T, ca *servertest.CA, addr string, cert tls.Certificate, remoteAddr string, clientCerts ...tls.Certificate) error {
+	t.Helper()
+
+	r := httptest.NewRequest("GET", "https://localhost/", nil)
+	r.RemoteAddr = remoteAddr
+	if len(clientCerts) > 0 {
+		r.TLS.VerifiedChains = append(r.TLS.VerifiedChains, nil)
+		for _, c := range clientCerts {
+			r.TLS.VerifiedChains[0] = append(r.TLS.VerifiedChains[0], c.Leaf)
+		}
+	}
+
+	return call(t, addr, ca.ClientCredentials(cert), Metadata(r))
+}
+
+// call makes a health check to addr with creds, sending md.
+func call(t *testing.T, addr string, creds credentials.TransportCredentials, md metadata.MD) error {
+	t.Helper()
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		t.Fatalf("dial failed: %s", err)
+	}
+	defer conn.Close()
+
+	ctx := metadata.NewOutgoingContext(servertest.Context(t), md)
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestForwarded(t *testing.T) {
+	ca := servertest.NewCA(t)
+	proxy := ca.Issue(t, "localhost")
+	alice := ca.Issue(t, "alice.example")
+	mallory := ca.Issue(t, "mallory.example")
+
+	addr, keys := serve(t, ca, &proxy)
+
+	tests := []struct {
+		name    string
+		caller  tls.Certificate
+		remote  string
+		client  []tls.Certificate
+		wantKey string
+	}{
+		{"client certificate", proxy, "203.0.113.7:5555", []tls.Certificate{alice}, "cert:CN=alice.example"},
+		{"client address", proxy, "203.0.113.7:5555", nil, "ip:203.0.113.7"},
+		// Only the server's own HTTP servers may vouch for a client.
+		{"untrusted caller", mallory, "203.0.113.7:5555", []tls.Certificate{alice}, "cert:CN=mallory.example"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := forward(t, ca, addr, tc.caller, tc.remote, tc.client...); err != nil {
+				t.Fatalf("call failed: %s", err)
+			}
+			if key := <-keys; key != tc.wantKey {
+				t.Errorf("client key %q, want %q", key, tc.wantKey)
+			}
+		})
+	}
+}
+
+// Calls made directly by the trusted caller keep its own identity.
+func TestNotForwarded(t *testing.T) {
+	ca := servertest.NewCA(t)
+	proxy := ca.Issue(t, "localhost")
+	addr, keys := serve(t, ca, &proxy)
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(ca.ClientCredentials(proxy)))
+	if err != nil {
+		t.Fatalf("dial failed: %s", err)
+	}
+	defer conn.Close()
+
+	if _, err := healthpb.NewHealthClient(conn).Check(servertest.Context(t), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("call failed: %s", err)
+	}
+	if key, want := <-keys, "cert:CN=localhost"; key != want {
+		t.Errorf("client key %q, want %q", key, want)
+	}
+}
+
+// Without TLS, only callers over loopback which know the secret of the
+// process are trusted.
+func TestPlaintext(t *testing.T) {
+	addr, keys := serveWith(t, insecure.NewCredentials(), nil)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "203.0.113.7:5555"
+
+	tests := []struct {
+		name    string
+		md      metadata.MD
+		wantKey string
+	}{
+		{"own HTTP server", Metadata(r), "ip:203.0.113.7"},
+		{"no secret", metadata.Pairs(PeerKey, "203.0.113.7:5555"), "ip:127.0.0.1"},
+		{"wrong secret", metadata.Pairs(SecretKey, newSecret(), PeerKey, "203.0.113.7:5555"), "ip:127.0.0.1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := call(t, addr, insecure.NewCredentials(), tc.md); err != nil {
+				t.Fatalf("call failed: %s", err)
+			}
+			if key := <-keys; key != tc.wantKey {
+				t.Errorf("client key %q, want %q", key, tc.wantKey)
+			}
+		})
+	}
+}
+
+func TestInvalidPeer(t *testing.T) {
+	ca := servertest.NewCA(t)
+	proxy := ca.Issue(t, "localhost")
+	addr, _ := serve(t, ca, &proxy)
+
+	err := forward(t, ca, addr, proxy, "not an address")
+	servertest.ExpectCode(t, err, codes.Internal)
+}
+
+func TestReserved(t *testing.T) {
+	for _, key := range []string{"x-forwarded-peer", "X-Forwarded-Client-Cert-Bin", "x-forwarded-secret"} {
+		if !Reserved(key) {
+			t.Errorf("%s is not reserved", key)
+		}
+	}
+	if Reserved("x-forwarded-for") {
+		t.Error("x-forwarded-for is reserved")
+	}
+}
@@ -0,0 +1,103 @@
+// Package gateway serves a REST/JSON front end to the gRPC services, so that
+// curl and browsers can call them. HTTP requests are translated into gRPC
+// calls according to the google.api.http annotations in the .proto files and
+// forwarded to the server's own gRPC port, so they pass through the same
+// interceptors as any other call. The identity of the HTTP client is passed
+// along with each call, so that the gRPC server can tell clients apart.
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/devries/grpc-tutorial/forwarded"
+	"github.com/devries/grpc-tutorial/logging"
+)
+
+// RegisterFunc registers the HTTP handlers of one service, such as
+// api.RegisterPrimesHandler.
+type RegisterFunc func(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error
+
+// headerMatcher forwards the request ID header along with the headers the
+// gateway forwards by default, except those claiming to identify the client,
+// which only the gateway itself may set.
+func headerMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, logging.RequestIDHeader) {
+		return logging.RequestIDHeader, true
+	}
+	name, ok := runtime.DefaultHeaderMatcher(key)
+	if ok && forwarded.Reserved(name) {
+		return "", false
+	}
+	return name, ok
+}
+
+// clientMetadata identifies the HTTP client to the gRPC server.
+func clientMetadata(_ context.Context, r *http.Request) metadata.MD {
+	return forwarded.Metadata(r)
+}
+
+// Handler returns an HTTP handler which forwards calls to the gRPC server at
+// target, dialed with creds.
+func Handler(ctx context.Context, target string, creds credentials.TransportCredentials, register ...RegisterFunc) (http.Handler, error) {
+	conn, err := grpc.DialContext(ctx, target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+
+	mux := runtime.NewServeMux(runtime.WithIncomingHeaderMatcher(headerMatcher), runtime.WithMetadata(clientMetadata))
+	for _, r := range register {
+		if err := r(ctx, mux, conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return mux, nil
+}
+
+// ServeFromEnv serves the gateway in the background on the port in the
+// GATEWAY_PORT environment variable, forwarding calls to the gRPC server at
+// target. If tlsConfig is not nil the gateway is served over HTTPS with it,
+// so that it asks clients for the same certificates as the gRPC server.
+// It returns the HTTP server, so that it can be shut down, or nil if
+// GATEWAY_PORT is unset and nothing is served.
+func ServeFromEnv(target string, creds credentials.TransportCredentials, tlsConfig *tls.Config, register ...RegisterFunc) (*http.Server, error) {
+	port := os.Getenv("GATEWAY_PORT")
+	if port == "" {
+		return nil, nil
+	}
+
+	handler, err := Handler(context.Background(), target, creds, register...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The server adds HTTP/2 to the protocols of its TLS configuration, so
+	// give it a copy of its own.
+	srv := &http.Server{Addr: ":" + port, Handler: handler, TLSConfig: tlsConfig.Clone()}
+
+	log.Printf("Serving REST gateway on port %s", port)
+	go func() {
+		var err error
+		if tlsConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			log.Fatalf("Failed to serve REST gateway: %s", err)
+		}
+	}()
+
+	return srv, nil
+}
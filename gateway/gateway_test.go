@@ -0,0 +1,216 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/peer"
+
+	"github.com/devries/grpc-tutorial/forwarded"
+	"github.com/devries/grpc-tutorial/generator"
+	"github.com/devries/grpc-tutorial/logging"
+	primesv1 "github.com/devries/grpc-tutorial/primes/v1"
+	"github.com/devries/grpc-tutorial/validate"
+)
+
+// clientAddr is the address of the HTTP client in every request.
+const clientAddr = "192.0.2.1:1234"
+
+// serve runs the primes service over a loopback port and returns a gateway to
+// it, and a channel receiving the peer of each call as the server sees it.
+func serve(t *testing.T) (http.Handler, <-chan string) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+
+	peers := make(chan string, 1)
+	record := func(ctx context.Context) {
+		p, _ := peer.FromContext(ctx)
+		select {
+		case peers <- p.Addr.String():
+		default:
+		}
+	}
+	opts := forwarded.ServerOptions(nil)
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			record(ctx)
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			record(ss.Context())
+			return handler(srv, ss)
+		}),
+	)
+	opts = append(opts, validate.ServerOptions()...)
+	s := grpc.NewServer(opts...)
+	primesv1.RegisterPrimesServiceServer(s, generator.NewService(nil))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	h, err := Handler(ctx, lis.Addr().String(), insecure.NewCredentials(), primesv1.RegisterPrimesServiceHandler)
+	if err != nil {
+		t.Fatalf("could not create gateway: %s", err)
+	}
+
+	return h, peers
+}
+
+// get makes a request for target to h with header, and returns the response.
+func get(h http.Handler, target string, header http.Header) *http.Response {
+	r := httptest.NewRequest("GET", target, nil)
+	r.RemoteAddr = clientAddr
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Result()
+}
+
+func TestGetPrimes(t *testing.T) {
+	h, peers := serve(t)
+
+	resp := get(h, "/v1/primes?number=5", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var body struct {
+		Contents  []string `json:"contents"`
+		Truncated bool     `json:"truncated"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("could not decode response: %s", err)
+	}
+
+	want := []string{"2", "3", "5", "7", "11"}
+	if len(body.Contents) != len(want) || body.Truncated {
+		t.Fatalf("got %v, want %v", body, want)
+	}
+	for i := range want {
+		if body.Contents[i] != want[i] {
+			t.Errorf("got %v, want %v", body.Contents, want)
+			break
+		}
+	}
+	if p := <-peers; p != clientAddr {
+		t.Errorf("server saw peer %s, want %s", p, clientAddr)
+	}
+}
+
+func TestStreamPrimes(t *testing.T) {
+	h, peers := serve(t)
+
+	resp := get(h, "/v1/primes:stream?number=3", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	want := []string{"2", "3", "5"}
+	var got []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var line struct {
+			Result struct {
+				Value string `json:"value"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("could not decode %q: %s", scanner.Text(), err)
+		}
+		got = append(got, line.Result.Value)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+			break
+		}
+	}
+	if p := <-peers; p != clientAddr {
+		t.Errorf("server saw peer %s, want %s", p, clientAddr)
+	}
+}
+
+// gRPC errors are returned as google.rpc.Status objects with a matching HTTP
+// status code.
+func TestErrors(t *testing.T) {
+	h, _ := serve(t)
+
+	resp := get(h, "/v1/primes?number=-1", nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	var body struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Details []struct {
+			Type string `json:"@type"`
+		} `json:"details"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("could not decode response: %s", err)
+	}
+	if body.Code != 3 {
+		t.Errorf("got code %d, want 3 (InvalidArgument)", body.Code)
+	}
+	if len(body.Details) != 1 || body.Details[0].Type != "type.googleapis.com/google.rpc.BadRequest" {
+		t.Errorf("got details %v, want a google.rpc.BadRequest", body.Details)
+	}
+}
+
+// An HTTP client cannot claim to be someone else.
+func TestForwardedHeaders(t *testing.T) {
+	h, peers := serve(t)
+
+	header := http.Header{
+		"Grpc-Metadata-X-Forwarded-Peer":   {"198.51.100.1:1"},
+		"Grpc-Metadata-X-Forwarded-Secret": {"guess"},
+	}
+	resp := get(h, "/v1/primes?number=1", header)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if p := <-peers; p != clientAddr {
+		t.Errorf("server saw peer %s, want %s", p, clientAddr)
+	}
+}
+
+func TestHeaderMatcher(t *testing.T) {
+	tests := []struct {
+		key  string
+		name string
+		ok   bool
+	}{
+		{"X-Request-Id", logging.RequestIDHeader, true},
+		{"Grpc-Metadata-Foo", "Foo", true},
+		{"Grpc-Metadata-X-Forwarded-Peer", "", false},
+		{"Grpc-Metadata-X-Forwarded-Client-Cert-Bin", "", false},
+		{"Grpc-Metadata-X-Forwarded-Secret", "", false},
+		{"X-Forwarded-Peer", "", false},
+		{"X-Unknown", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.key, func(t *testing.T) {
+			name, ok := headerMatcher(tc.key)
+			if name != tc.name || ok != tc.ok {
+				t.Errorf("got %q, %v, want %q, %v", name, ok, tc.name, tc.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Package generator finds primes for the Go servers, and serves both methods
+// of primes.v1.PrimesService with them, so that every server answers
+// GetPrimes and StreamPrimes alike rather than advertising a method it does
+// not implement.
+package generator
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"google.golang.org/grpc/status"
+
+	"github.com/devries/grpc-tutorial/admission"
+	"github.com/devries/grpc-tutorial/deadline"
+	"github.com/devries/grpc-tutorial/metrics"
+	primesv1 "github.com/devries/grpc-tutorial/primes/v1"
+)
+
+// Service implements primes.v1.PrimesService. Each call runs its own prime
+// generator, admitted by the admission controller, which may be nil.
+type Service struct {
+	primesv1.UnimplementedPrimesServiceServer
+	admission *admission.Controller
+	deadlines *deadline.Estimator
+}
+
+// NewService returns the service, admitting generators with admit.
+func NewService(admit *admission.Controller) *Service {
+	return &Service{admission: admit, deadlines: deadline.NewEstimator()}
+}
+
+// GetPrimes returns the first primes all at once.
+func (s *Service) GetPrimes(ctx context.Context, in *primesv1.PrimeCount) (*primesv1.PrimeNumbers, error) {
+	slog.InfoContext(ctx, "Received request", "number", in.Number)
+
+	contentBox := make([]int64, in.Number)
+
+	if err := s.deadlines.Check(ctx, in.Number, in.AllowPartial); err != nil {
+		slog.WarnContext(ctx, "Not enough time before the deadline", "number", in.Number)
+		return nil, err
+	}
+
+	// There is no need for a generator when no primes are asked for.
+	if in.Number == 0 {
+		return &primesv1.PrimeNumbers{}, nil
+	}
+
+	release, err := s.admission.Admit(ctx, in.Number)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not admit request", "number", in.Number, "error", status.Convert(err).Message())
+		return nil, err
+	}
+	defer release()
+
+	// Prepare prime generator
+	ch := make(chan int64)
+	ctx, cancel := context.WithCancel(ctx)
+
+	generated := make(chan struct{})
+	go func() {
+		defer close(generated)
+		Primes(ctx, ch)
+	}()
+
+	// Clients accepting partial results get the primes found before their
+	// deadline.
+	cutoff, stopCutoff := deadline.Cutoff(ctx, in.AllowPartial)
+	defer stopCutoff()
+
+	start := time.Now()
+	var i int64
+gather:
+	for i = 0; i < in.Number; i++ {
+		select {
+		case contentBox[i] = <-ch:
+		case <-cutoff:
+			slog.WarnContext(ctx, "Returning partial results at the deadline", "number", in.Number, "found", i)
+			break gather
+		}
+	}
+	// A call abandoned by the client says nothing about how long primes
+	// take to find.
+	if ctx.Err() == nil {
+		s.deadlines.Observe(i, time.Since(start))
+	}
+	// The generator has stopped before its admission slot is released.
+	cancel()
+	<-generated
+
+	return &primesv1.PrimeNumbers{Contents: contentBox[:i], Truncated: i < in.Number}, nil
+}
+
+// StreamPrimes streams the first primes one at a time as they are found.
+func (s *Service) StreamPrimes(in *primesv1.PrimeCount, stream primesv1.PrimesService_StreamPrimesServer) error {
+	ctx := stream.Context()
+	slog.InfoContext(ctx, "Received request", "number", in.Number)
+
+	// There is no need for a generator when no primes are asked for.
+	if in.Number == 0 {
+		return nil
+	}
+
+	release, err := s.admission.Admit(ctx, in.Number)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not admit request", "number", in.Number, "error", status.Convert(err).Message())
+		return err
+	}
+	defer release()
+
+	// Prepare prime generator
+	ch := make(chan int64)
+	ctx, cancel := context.WithCancel(ctx)
+
+	// The generator has stopped before its admission slot is released.
+	generated := make(chan struct{})
+	defer func() {
+		cancel()
+		<-generated
+	}()
+	go func() {
+		defer close(generated)
+		Primes(ctx, ch)
+	}()
+
+	for i := int64(0); i < in.Number; i++ {
+		n := primesv1.PrimeNumber{Count: i + 1, Value: <-ch}
+		if err := stream.Send(&n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Primes sends the primes in order on ch until ctx is done, when it closes
+// ch.
+func Primes(ctx context.Context, ch chan<- int64) {
+	defer metrics.GeneratorTimer().ObserveDuration()
+
+	primes := make([]int64, 0)
+	select {
+	case ch <- int64(2):
+		metrics.PrimesGenerated.Inc()
+	case <-ctx.Done():
+		close(ch)
+		return
+	}
+	for i := int64(3); ; i += 2 {
+		isprime := true
+		iSqrt := int64(math.Floor(math.Sqrt(float64(i))))
+		for _, p := range primes {
+			if i%p == 0 {
+				isprime = false
+				break
+			}
+			if p > iSqrt {
+				break
+			}
+		}
+		if isprime {
+			select {
+			case ch <- i:
+				primes = append(primes, i)
+				metrics.PrimesGenerated.Inc()
+			case <-ctx.Done():
+				close(ch)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/devries/grpc-tutorial/servertest"
+)
+
+func TestGeneratorsStop(t *testing.T) {
+	srv := NewService(nil)
+	servertest.CheckGeneratorsStop(t, srv.GetPrimes)
+	servertest.CheckStreamGeneratorsStop(t, srv.StreamPrimes)
+}
@@ -0,0 +1,22 @@
+// Package primesmsg describes the messages of the primes services by the
+// methods they share, so that interceptors can count primes in the messages
+// of every version of the services without knowing their types.
+package primesmsg
+
+// Number is implemented by requests for some number of primes, such as
+// primesv1.PrimeCount.
+type Number interface {
+	GetNumber() int64
+}
+
+// Contents is implemented by responses containing a list of primes, such as
+// primesv1.PrimeNumbers.
+type Contents interface {
+	GetContents() []int64
+}
+
+// Value is implemented by streamed messages carrying one prime, such as
+// primesv1.PrimeNumber.
+type Value interface {
+	GetValue() int64
+}
@@ -0,0 +1,60 @@
+// Package legacy serves the unversioned api.Primes and apistream.PrimeStream
+// services by forwarding their calls to a primes.v1 PrimesService, so that
+// clients built before the versioned API keep working against servers which
+// only implement primes.v1. The old and new messages have the same fields, so
+// each call is translated one message at a time.
+package legacy
+
+import (
+	"context"
+
+	"github.com/devries/grpc-tutorial/api"
+	"github.com/devries/grpc-tutorial/apistream"
+	primesv1 "github.com/devries/grpc-tutorial/primes/v1"
+)
+
+// Primes returns an api.Primes server which calls srv.GetPrimes.
+func Primes(srv primesv1.PrimesServiceServer) api.PrimesServer {
+	return &primes{srv: srv}
+}
+
+type primes struct {
+	api.UnimplementedPrimesServer
+	srv primesv1.PrimesServiceServer
+}
+
+func (p *primes) GetPrimes(ctx context.Context, in *api.PrimeCount) (*api.PrimeNumbers, error) {
+	resp, err := p.srv.GetPrimes(ctx, &primesv1.PrimeCount{Number: in.Number, AllowPartial: in.AllowPartial})
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.PrimeNumbers{Contents: resp.Contents, Truncated: resp.Truncated}, nil
+}
+
+// PrimeStream returns an apistream.PrimeStream server which calls
+// srv.StreamPrimes.
+func PrimeStream(srv primesv1.PrimesServiceServer) apistream.PrimeStreamServer {
+	return &primeStream{srv: srv}
+}
+
+type primeStream struct {
+	apistream.UnimplementedPrimeStreamServer
+	srv primesv1.PrimesServiceServer
+}
+
+func (p *primeStream) GetPrimes(in *apistream.PrimeCount, stream apistream.PrimeStream_GetPrimesServer) error {
+	return p.srv.StreamPrimes(&primesv1.PrimeCount{Number: in.Number}, &primeStreamServer{stream})
+}
+
+// primeStreamServer sends the primes of a primes.v1 stream as
+// apistream.PrimeNumber messages.
+type primeStreamServer struct {
+	apistream.PrimeStream_GetPrimesServer
+}
+
+func (s *primeStreamServer) Send(n *primesv1.PrimeNumber) error {
+	return s.PrimeStream_GetPrimesServer.Send(&apistream.PrimeNumber{Count: n.Count, Value: n.Value})
+}
+
+var _ primesv1.PrimesService_StreamPrimesServer = (*primeStreamServer)(nil)
@@ -0,0 +1,198 @@
+package legacy
+
+import (
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/devries/grpc-tutorial/api"
+	"github.com/devries/grpc-tutorial/apistream"
+	primesv1 "github.com/devries/grpc-tutorial/primes/v1"
+	"github.com/devries/grpc-tutorial/validate"
+)
+
+var firstPrimes = []int64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29}
+
+// v1Server implements only primes.v1, returning the first ten primes.
+type v1Server struct {
+	primesv1.UnimplementedPrimesServiceServer
+}
+
+func (s *v1Server) GetPrimes(ctx context.Context, in *primesv1.PrimeCount) (*primesv1.PrimeNumbers, error) {
+	if in.Number > int64(len(firstPrimes)) {
+		return &primesv1.PrimeNumbers{Contents: firstPrimes, Truncated: in.AllowPartial}, nil
+	}
+	return &primesv1.PrimeNumbers{Contents: firstPrimes[:in.Number]}, nil
+}
+
+func (s *v1Server) StreamPrimes(in *primesv1.PrimeCount, stream primesv1.PrimesService_StreamPrimesServer) error {
+	stream.SetHeader(metadata.Pairs("x-test", "header"))
+	for i, p := range firstPrimes[:min(in.Number, int64(len(firstPrimes)))] {
+		if err := stream.Send(&primesv1.PrimeNumber{Count: int64(i + 1), Value: p}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dial serves primes.v1 and the unversioned services, as the servers do, and
+// returns a connection to them.
+func dial(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer(validate.ServerOptions()...)
+	srv := &v1Server{}
+	primesv1.RegisterPrimesServiceServer(s, srv)
+	api.RegisterPrimesServer(s, Primes(srv))
+	apistream.RegisterPrimeStreamServer(s, PrimeStream(srv))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial failed: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestPrimesClient(t *testing.T) {
+	c := api.NewPrimesClient(dial(t))
+	ctx := testContext(t)
+
+	r, err := c.GetPrimes(ctx, &api.PrimeCount{Number: 5})
+	if err != nil {
+		t.Fatalf("GetPrimes failed: %s", err)
+	}
+	if want := firstPrimes[:5]; !reflect.DeepEqual(r.Contents, want) || r.Truncated {
+		t.Errorf("got %v (truncated %t), want %v", r.Contents, r.Truncated, want)
+	}
+
+	r, err = c.GetPrimes(ctx, &api.PrimeCount{Number: 20, AllowPartial: true})
+	if err != nil {
+		t.Fatalf("GetPrimes failed: %s", err)
+	}
+	if !r.Truncated {
+		t.Errorf("partial result not marked as truncated")
+	}
+}
+
+func TestPrimeStreamClient(t *testing.T) {
+	c := apistream.NewPrimeStreamClient(dial(t))
+
+	stream, err := c.GetPrimes(testContext(t), &apistream.PrimeCount{Number: 4})
+	if err != nil {
+		t.Fatalf("GetPrimes failed: %s", err)
+	}
+
+	header, err := stream.Header()
+	if err != nil {
+		t.Fatalf("could not read header: %s", err)
+	}
+	if got := header.Get("x-test"); len(got) != 1 || got[0] != "header" {
+		t.Errorf("got header %v, want [header]", got)
+	}
+
+	var got []int64
+	for {
+		n, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %s", err)
+		}
+		if n.Count != int64(len(got)+1) {
+			t.Errorf("got count %d, want %d", n.Count, len(got)+1)
+		}
+		got = append(got, n.Value)
+	}
+	if want := firstPrimes[:4]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// The limits of the unversioned services must still hold, and the shared
+// primes.v1 request must be limited per method.
+func TestLimits(t *testing.T) {
+	conn := dial(t)
+	ctx := testContext(t)
+
+	_, err := api.NewPrimesClient(conn).GetPrimes(ctx, &api.PrimeCount{Number: 501})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("api.Primes with 501 primes: got %s, want InvalidArgument", code)
+	}
+
+	v1 := primesv1.NewPrimesServiceClient(conn)
+	_, err = v1.GetPrimes(ctx, &primesv1.PrimeCount{Number: 501})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("primes.v1 GetPrimes with 501 primes: got %s, want InvalidArgument", code)
+	}
+
+	stream, err := v1.StreamPrimes(ctx, &primesv1.PrimeCount{Number: 501})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if err != nil {
+		t.Errorf("primes.v1 StreamPrimes with 501 primes failed: %s", err)
+	}
+
+	stream, err = v1.StreamPrimes(ctx, &primesv1.PrimeCount{Number: -1})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("primes.v1 StreamPrimes with -1 primes: got %s, want InvalidArgument", code)
+	}
+}
+
+// Messages of the unversioned services must decode as their primes.v1
+// counterparts, so that either may be sent to either.
+func TestWireCompatibility(t *testing.T) {
+	tests := []struct {
+		old, v1 proto.Message
+	}{
+		{&api.PrimeCount{Number: 7, AllowPartial: true}, &primesv1.PrimeCount{Number: 7, AllowPartial: true}},
+		{&apistream.PrimeCount{Number: 7}, &primesv1.PrimeCount{Number: 7}},
+		{&api.PrimeNumbers{Contents: []int64{2, 3}, Truncated: true}, &primesv1.PrimeNumbers{Contents: []int64{2, 3}, Truncated: true}},
+		{&apistream.PrimeNumber{Count: 2, Value: 3}, &primesv1.PrimeNumber{Count: 2, Value: 3}},
+	}
+
+	for _, tc := range tests {
+		b, err := proto.Marshal(tc.old)
+		if err != nil {
+			t.Fatalf("could not marshal %T: %s", tc.old, err)
+		}
+		got := tc.v1.ProtoReflect().New().Interface()
+		if err := proto.Unmarshal(b, got); err != nil {
+			t.Fatalf("could not unmarshal %T as %T: %s", tc.old, tc.v1, err)
+		}
+		if !proto.Equal(got, tc.v1) {
+			t.Errorf("%T decoded as %v, want %v", tc.old, got, tc.v1)
+		}
+	}
+}
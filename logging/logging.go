@@ -0,0 +1,206 @@
+// Package logging sets up structured logging for the servers with log/slog
+// and logs every RPC with its request ID, method, peer, client certificate,
+// authentication result, duration, and status code.
+//
+// The request ID is taken from the x-request-id metadata header, or generated
+// if the client did not send one, and is echoed back in the x-request-id
+// response header. Log records written with the slog *Context functions
+// during a call include the request ID.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader is the metadata key carrying the request ID.
+const RequestIDHeader = "x-request-id"
+
+// Setup installs a default slog logger configured by the LOG_FORMAT ("text"
+// or "json") and LOG_LEVEL ("debug", "info", "warn", or "error") environment
+// variables. Output from the standard log package goes through the same
+// logger.
+func Setup() error {
+	var level slog.Level
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("LOG_LEVEL must be debug, info, warn, or error, not %q", v)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	switch v := strings.ToLower(os.Getenv("LOG_FORMAT")); v {
+	case "", "text":
+		h = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("LOG_FORMAT must be text or json, not %q", v)
+	}
+
+	slog.SetDefault(slog.New(contextHandler{h}))
+	return nil
+}
+
+// contextHandler adds the request ID of the current call to log records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if c := fromContext(ctx); c != nil {
+		r.AddAttrs(slog.String("request_id", c.requestID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// call holds what is known about the current RPC.
+type call struct {
+	requestID  string
+	authorized *bool
+}
+
+type contextKey int
+
+var contextKeyCall = contextKey(1)
+
+func fromContext(ctx context.Context) *call {
+	if ctx == nil {
+		return nil
+	}
+	c, _ := ctx.Value(contextKeyCall).(*call)
+	return c
+}
+
+// RequestID returns the request ID of the current call, or an empty string
+// outside of a call.
+func RequestID(ctx context.Context) string {
+	if c := fromContext(ctx); c != nil {
+		return c.requestID
+	}
+	return ""
+}
+
+// SetAuthorized records the outcome of authenticating the client, to be
+// logged when the call completes.
+func SetAuthorized(ctx context.Context, authorized bool) {
+	if c := fromContext(ctx); c != nil {
+		c.authorized = &authorized
+	}
+}
+
+// ServerOptions returns the interceptors which assign request IDs and log
+// every call.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor),
+	}
+}
+
+// UnaryServerInterceptor assigns a request ID to unary calls and logs them.
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, c := start(ctx)
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, c.requestID))
+
+	begin := time.Now()
+	resp, err := handler(ctx, req)
+	logCall(ctx, c, info.FullMethod, begin, err)
+
+	return resp, err
+}
+
+// StreamServerInterceptor assigns a request ID to streaming calls and logs
+// them.
+func StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, c := start(ss.Context())
+	ss.SetHeader(metadata.Pairs(RequestIDHeader, c.requestID))
+
+	begin := time.Now()
+	err := handler(srv, &loggedStream{ServerStream: ss, ctx: ctx})
+	logCall(ctx, c, info.FullMethod, begin, err)
+
+	return err
+}
+
+// loggedStream carries the context holding the request ID.
+type loggedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *loggedStream) Context() context.Context {
+	return s.ctx
+}
+
+func start(ctx context.Context) (context.Context, *call) {
+	c := &call{}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDHeader); len(ids) > 0 && ids[0] != "" {
+			c.requestID = ids[0]
+		}
+	}
+	if c.requestID == "" {
+		c.requestID = newRequestID()
+	}
+
+	return context.WithValue(ctx, contextKeyCall, c), c
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// Without randomness fall back to a timestamp, which is still
+		// useful for finding the call in the logs.
+		return fmt.Sprintf("t%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+func logCall(ctx context.Context, c *call, method string, begin time.Time, err error) {
+	st := status.Convert(err)
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.Duration("duration", time.Since(begin)),
+		slog.String("code", st.Code().String()),
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		attrs = append(attrs, slog.String("peer", p.Addr.String()))
+		if tlsAuth, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsAuth.State.PeerCertificates) > 0 {
+			attrs = append(attrs, slog.String("cert_subject", tlsAuth.State.PeerCertificates[0].Subject.String()))
+		}
+	}
+	if c.authorized != nil {
+		attrs = append(attrs, slog.Bool("authorized", *c.authorized))
+	}
+
+	level := slog.LevelInfo
+	if err != nil {
+		attrs = append(attrs, slog.String("error", st.Message()))
+		level = slog.LevelWarn
+	}
+
+	slog.LogAttrs(ctx, level, "Finished call", attrs...)
+}
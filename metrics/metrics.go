@@ -0,0 +1,140 @@
+// Package metrics exposes Prometheus metrics for the servers: request counts,
+// latencies, and in-flight streams for every RPC, and counters for the primes
+// generated and sent to clients.
+package metrics
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/devries/grpc-tutorial/internal/primesmsg"
+)
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "primes_rpc_requests_total",
+		Help: "Number of RPCs completed, by method and status code.",
+	}, []string{"method", "code"})
+
+	latency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "primes_rpc_duration_seconds",
+		Help:    "Time taken to complete RPCs, by method.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 4, 10),
+	}, []string{"method"})
+
+	inFlightStreams = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "primes_rpc_in_flight_streams",
+		Help: "Number of streaming RPCs in progress, by method.",
+	}, []string{"method"})
+
+	primesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "primes_sent_total",
+		Help: "Number of primes sent to clients, by method.",
+	}, []string{"method"})
+
+	// PrimesGenerated counts every prime produced by a prime generator.
+	PrimesGenerated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "primes_generated_total",
+		Help: "Number of primes produced by the prime generators.",
+	})
+
+	generatorDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "primes_generator_duration_seconds",
+		Help:    "Time each prime generator spent running.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 12),
+	})
+)
+
+// GeneratorTimer starts timing a prime generator. Call ObserveDuration on
+// the result when the generator returns.
+func GeneratorTimer() *prometheus.Timer {
+	return prometheus.NewTimer(generatorDuration)
+}
+
+// ServeFromEnv serves the /metrics endpoint in the background on the port
+// in the METRICS_PORT environment variable. It returns the HTTP server, so
+// that it can be shut down, or nil if METRICS_PORT is unset and nothing is
+// served.
+func ServeFromEnv() *http.Server {
+	port := os.Getenv("METRICS_PORT")
+	if port == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := &http.Server{Addr: ":" + port, Handler: mux}
+
+	log.Printf("Serving metrics on port %s", port)
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatalf("Failed to serve metrics: %s", err)
+		}
+	}()
+
+	return srv
+}
+
+// ServerOptions returns the interceptors which record RPC metrics.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor),
+	}
+}
+
+// UnaryServerInterceptor records the outcome and latency of unary RPCs, and
+// the number of primes returned.
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	observe(info.FullMethod, start, err)
+
+	if c, ok := resp.(primesmsg.Contents); ok && err == nil {
+		primesSent.WithLabelValues(info.FullMethod).Add(float64(len(c.GetContents())))
+	}
+
+	return resp, err
+}
+
+// StreamServerInterceptor records the outcome and latency of streaming RPCs,
+// the number in progress, and the number of primes sent.
+func StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	gauge := inFlightStreams.WithLabelValues(info.FullMethod)
+	gauge.Inc()
+	defer gauge.Dec()
+
+	err := handler(srv, &countingStream{ServerStream: ss, sent: primesSent.WithLabelValues(info.FullMethod)})
+	observe(info.FullMethod, start, err)
+
+	return err
+}
+
+func observe(method string, start time.Time, err error) {
+	latency.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	requests.WithLabelValues(method, status.Code(err).String()).Inc()
+}
+
+// countingStream counts the primes sent on a stream.
+type countingStream struct {
+	grpc.ServerStream
+	sent prometheus.Counter
+}
+
+func (s *countingStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if _, ok := m.(primesmsg.Value); ok && err == nil {
+		s.sent.Inc()
+	}
+	return err
+}
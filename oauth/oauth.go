@@ -0,0 +1,173 @@
+// Package oauth authenticates clients with access tokens from an OAuth 2.0
+// authorization server, using the client credentials grant (RFC 6749
+// section 4.4). The client exchanges its ID and secret for an access token
+// at the server's token endpoint, and sends it as a bearer token with each
+// call. The token is cached and used until shortly before it expires, when a
+// new one is fetched.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRefreshBefore is how long before a token expires it is replaced, if
+// RefreshBefore is zero.
+const DefaultRefreshBefore = 30 * time.Second
+
+// ClientCredentials is a grpc.PerRPCCredentials which sends access tokens
+// fetched with the client credentials grant.
+type ClientCredentials struct {
+	// TokenURL is the token endpoint of the authorization server.
+	TokenURL string
+	// ClientID and ClientSecret identify the client to the authorization
+	// server.
+	ClientID     string
+	ClientSecret string
+	// Scopes are the scopes requested for the token, if any.
+	Scopes []string
+	// RefreshBefore is how long before a token expires a new one is
+	// fetched, so that calls are not sent with a token which expires on
+	// the way. Tokens are always used for at least half their lifetime, so
+	// that short-lived tokens are not fetched for every call.
+	RefreshBefore time.Duration
+	// HTTPClient makes the token requests. If nil, http.DefaultClient is
+	// used.
+	HTTPClient *http.Client
+
+	mu       sync.Mutex
+	token    string
+	lifetime time.Duration
+	expiry   time.Time
+	now      func() time.Time
+}
+
+// tokenResponse is the body of a successful token response, or of an error
+// response (RFC 6749 sections 5.1 and 5.2).
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// GetRequestMetadata returns the authorization header carrying the access
+// token, fetching a new token if there is none or it is about to expire.
+func (c *ClientCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := c.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity reports that tokens are only sent over TLS.
+func (c *ClientCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+// Token returns the cached access token, fetching a new one if there is none
+// or it is due to be refreshed. If a refresh fails while the cached token is
+// still valid, the cached token is returned and the refresh is tried again on
+// the next call.
+func (c *ClientCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock()
+	refreshBefore := c.RefreshBefore
+	if refreshBefore == 0 {
+		refreshBefore = DefaultRefreshBefore
+	}
+	refreshBefore = min(refreshBefore, c.lifetime/2)
+	if c.token != "" && now.Add(refreshBefore).Before(c.expiry) {
+		return c.token, nil
+	}
+
+	token, lifetime, err := c.fetch(ctx)
+	if err != nil {
+		if c.token != "" && now.Before(c.expiry) {
+			slog.WarnContext(ctx, "Could not refresh access token, using the current one until it expires", "expiry", c.expiry, "error", err)
+			return c.token, nil
+		}
+		return "", err
+	}
+
+	c.token, c.lifetime, c.expiry = token, lifetime, now.Add(lifetime)
+	slog.DebugContext(ctx, "Fetched access token", "expiry", c.expiry)
+	return c.token, nil
+}
+
+func (c *ClientCredentials) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// fetch requests a new access token from the token endpoint, returning it
+// along with how long it is valid for. Tokens without an expiry are treated
+// as valid for an hour. The error is Unauthenticated if the authorization
+// server refused the request, and Unavailable if it could not be reached.
+func (c *ClientCredentials) fetch(ctx context.Context) (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, status.Errorf(codes.Unauthenticated, "invalid token endpoint: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, status.Errorf(codes.Unavailable, "could not reach token endpoint: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&body)
+
+	switch {
+	case resp.StatusCode >= 500:
+		return "", 0, status.Errorf(codes.Unavailable, "token endpoint failed: %s", resp.Status)
+	case body.Error != "":
+		msg := body.Error
+		if body.ErrorDescription != "" {
+			msg += ": " + body.ErrorDescription
+		}
+		return "", 0, status.Errorf(codes.Unauthenticated, "token request refused: %s", msg)
+	case resp.StatusCode != http.StatusOK:
+		return "", 0, status.Errorf(codes.Unauthenticated, "token request refused: %s", resp.Status)
+	case decodeErr != nil:
+		return "", 0, status.Errorf(codes.Unauthenticated, "could not parse token response: %s", decodeErr)
+	case body.AccessToken == "":
+		return "", 0, status.Error(codes.Unauthenticated, "token response has no access token")
+	case body.TokenType != "" && !strings.EqualFold(body.TokenType, "bearer"):
+		return "", 0, status.Errorf(codes.Unauthenticated, "unsupported token type %q", body.TokenType)
+	}
+
+	lifetime := time.Hour
+	if body.ExpiresIn > 0 {
+		lifetime = time.Duration(body.ExpiresIn) * time.Second
+	}
+	return body.AccessToken, lifetime, nil
+}
@@ -0,0 +1,142 @@
+package oauth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/devries/grpc-tutorial/oauth/oauthtest"
+)
+
+// clock is a fake clock for the client, which only moves when told to.
+type clock struct{ t time.Time }
+
+func newClock() *clock { return &clock{t: time.Now()} }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func expectCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Errorf("got %s (%v), want %s", got, err, want)
+	}
+}
+
+func TestGetRequestMetadata(t *testing.T) {
+	server := oauthtest.NewServer(t, "client_five", "s3cret&more", time.Hour)
+	creds := &ClientCredentials{TokenURL: server.URL, ClientID: "client_five", ClientSecret: "s3cret&more", Scopes: []string{"primes.read", "primes.stream"}}
+
+	md, err := creds.GetRequestMetadata(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, ok := strings.CutPrefix(md["authorization"], "Bearer ")
+	if !ok {
+		t.Fatalf("authorization is %q, want a bearer token", md["authorization"])
+	}
+	scope, ok := server.Scope(token)
+	if !ok {
+		t.Fatal("token not issued by the server")
+	}
+	if scope != "primes.read primes.stream" {
+		t.Errorf("scope is %q", scope)
+	}
+}
+
+func TestCaching(t *testing.T) {
+	tests := []struct {
+		name          string
+		lifetime      time.Duration
+		refreshBefore time.Duration
+		// The token is reused until cached, and replaced by refreshed.
+		cached, refreshed time.Duration
+	}{
+		{"refreshed before expiry", time.Hour, time.Minute, 58 * time.Minute, 59*time.Minute + 30*time.Second},
+		// A token which lives no longer than RefreshBefore is still used
+		// for half its lifetime, rather than fetched for every call.
+		{"short lifetime", 20 * time.Second, 0, 9 * time.Second, 11 * time.Second},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := oauthtest.NewServer(t, "id", "secret", tc.lifetime)
+			c := newClock()
+			start := c.now()
+			creds := &ClientCredentials{TokenURL: server.URL, ClientID: "id", ClientSecret: "secret", RefreshBefore: tc.refreshBefore, now: c.now}
+			ctx := context.Background()
+
+			first, err := creds.Token(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c.t = start.Add(tc.cached)
+			if token, err := creds.Token(ctx); err != nil || token != first {
+				t.Errorf("got %q, %v, want the cached token", token, err)
+			}
+			if n := server.Requests(); n != 1 {
+				t.Errorf("%d token requests, want 1", n)
+			}
+
+			c.t = start.Add(tc.refreshed)
+			second, err := creds.Token(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if second == first {
+				t.Error("token not refreshed before expiry")
+			}
+			if n := server.Requests(); n != 2 {
+				t.Errorf("%d token requests, want 2", n)
+			}
+		})
+	}
+}
+
+func TestRefreshFailure(t *testing.T) {
+	server := oauthtest.NewServer(t, "id", "secret", time.Hour)
+	c := newClock()
+	creds := &ClientCredentials{TokenURL: server.URL, ClientID: "id", ClientSecret: "secret", now: c.now}
+	ctx := context.Background()
+
+	first, err := creds.Token(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The current token is used while it is still valid.
+	server.SetFailing(true)
+	c.advance(time.Hour - 10*time.Second)
+	if token, err := creds.Token(ctx); err != nil || token != first {
+		t.Errorf("got %q, %v, want the current token", token, err)
+	}
+
+	c.advance(20 * time.Second)
+	_, err = creds.Token(ctx)
+	expectCode(t, err, codes.Unavailable)
+
+	server.SetFailing(false)
+	if token, err := creds.Token(ctx); err != nil || token == first {
+		t.Errorf("got %q, %v, want a new token", token, err)
+	}
+}
+
+func TestRefused(t *testing.T) {
+	server := oauthtest.NewServer(t, "id", "secret", time.Hour)
+
+	creds := &ClientCredentials{TokenURL: server.URL, ClientID: "id", ClientSecret: "wrong"}
+	_, err := creds.GetRequestMetadata(context.Background())
+	expectCode(t, err, codes.Unauthenticated)
+	if err != nil && !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("error %q does not give the reason", err)
+	}
+
+	creds = &ClientCredentials{TokenURL: "http://127.0.0.1:1/token", ClientID: "id", ClientSecret: "secret"}
+	_, err = creds.GetRequestMetadata(context.Background())
+	expectCode(t, err, codes.Unavailable)
+}
@@ -0,0 +1,137 @@
+// Package oauthtest runs a fake OAuth 2.0 authorization server for tests. It
+// issues access tokens to one client with the client credentials grant, and
+// remembers them so that a test can check the tokens a server was sent.
+package oauthtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Server is a fake authorization server.
+type Server struct {
+	// URL is the token endpoint.
+	URL string
+
+	clientID     string
+	clientSecret string
+	lifetime     time.Duration
+
+	mu       sync.Mutex
+	tokens   map[string]grant
+	requests int
+	failing  bool
+}
+
+// grant is what a token was issued for.
+type grant struct {
+	scope  string
+	expiry time.Time
+}
+
+// NewServer starts an authorization server which issues tokens valid for
+// lifetime to the client with the given ID and secret. It is shut down when
+// the test ends.
+func NewServer(t testing.TB, clientID, clientSecret string, lifetime time.Duration) *Server {
+	s := &Server{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		lifetime:     lifetime,
+		tokens:       make(map[string]grant),
+	}
+
+	hs := httptest.NewServer(http.HandlerFunc(s.serveToken))
+	t.Cleanup(hs.Close)
+	s.URL = hs.URL + "/token"
+
+	return s
+}
+
+// Requests returns the number of token requests made, including refused
+// ones.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Scope returns the scope a token was issued with, and whether it was issued
+// by s and has not expired.
+func (s *Server) Scope(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := s.tokens[token]
+	if !ok || time.Now().After(g.expiry) {
+		return "", false
+	}
+	return g.scope, true
+}
+
+// SetFailing makes the server answer token requests with an internal server
+// error, or stop doing so.
+func (s *Server) SetFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	switch {
+	case s.failing:
+		http.Error(w, "token server failing", http.StatusInternalServerError)
+		return
+	case r.Method != http.MethodPost:
+		http.Error(w, "token requests must be POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	}
+	if !ok || id != s.clientID || secret != s.clientSecret {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauthtest"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if grantType := r.PostFormValue("grant_type"); grantType != "client_credentials" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type", "error_description": "grant_type " + grantType + " is not supported"})
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	token := hex.EncodeToString(b)
+	scope := r.PostFormValue("scope")
+	s.tokens[token] = grant{scope: scope, expiry: time.Now().Add(s.lifetime)}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int64(s.lifetime / time.Second),
+		"scope":        scope,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,146 @@
+// Package output writes the primes received by the clients in formats which
+// are easy to consume from scripts, rather than as a log message.
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Format is the name of an output format selected with the --output flag.
+type Format string
+
+const (
+	// Log is the original human readable log output of each client.
+	Log Format = "log"
+	// Plain writes one prime per line.
+	Plain Format = "plain"
+	// JSON writes a single JSON array of {"count": n, "value": p} objects.
+	JSON Format = "json"
+	// NDJSON writes one {"count": n, "value": p} object per line.
+	NDJSON Format = "ndjson"
+	// CSV writes a count,value header followed by one row per prime.
+	CSV Format = "csv"
+)
+
+// Usage describes the available formats for flag help text.
+const Usage = "output format: log, plain, json, ndjson, or csv"
+
+// ParseFormat checks that s names a known output format.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case Log, Plain, JSON, NDJSON, CSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (%s)", s, Usage)
+}
+
+// Writer writes primes one at a time in a machine readable format. Close must
+// be called once all primes have been written to complete the output.
+type Writer struct {
+	w      *bufio.Writer
+	format Format
+	n      int64
+}
+
+// record has the field names of apistream.PrimeNumber, but writes them as
+// JSON numbers, where protojson would write the int64 fields as strings, so
+// that scripts can use the values directly.
+type record struct {
+	Count int64 `json:"count"`
+	Value int64 `json:"value"`
+}
+
+// NewWriter returns a Writer for format which writes to w. The Log format is
+// left to the clients, so it is rejected here along with unknown formats.
+func NewWriter(w io.Writer, format Format) (*Writer, error) {
+	switch format {
+	case Plain, JSON, NDJSON, CSV:
+	default:
+		return nil, fmt.Errorf("output format %q is not machine readable", format)
+	}
+
+	return &Writer{w: bufio.NewWriter(w), format: format}, nil
+}
+
+// Write outputs the prime value, which is the count-th prime.
+func (o *Writer) Write(count, value int64) error {
+	var err error
+
+	switch o.format {
+	case Plain:
+		_, err = fmt.Fprintln(o.w, value)
+	case JSON:
+		sep := ",\n  "
+		if o.n == 0 {
+			sep = "[\n  "
+		}
+		if _, err = o.w.WriteString(sep); err == nil {
+			err = o.writeRecord(count, value)
+		}
+	case NDJSON:
+		if err = o.writeRecord(count, value); err == nil {
+			err = o.w.WriteByte('\n')
+		}
+	case CSV:
+		if o.n == 0 {
+			if _, err = o.w.WriteString("count,value\n"); err != nil {
+				return err
+			}
+		}
+		_, err = fmt.Fprintf(o.w, "%d,%d\n", count, value)
+	}
+
+	o.n++
+	return err
+}
+
+func (o *Writer) writeRecord(count, value int64) error {
+	bs, err := json.Marshal(record{Count: count, Value: value})
+	if err != nil {
+		return err
+	}
+	_, err = o.w.Write(bs)
+	return err
+}
+
+// Close completes the output and flushes it to the underlying writer.
+func (o *Writer) Close() error {
+	switch o.format {
+	case JSON:
+		closing := "\n]\n"
+		if o.n == 0 {
+			closing = "[]\n"
+		}
+		if _, err := o.w.WriteString(closing); err != nil {
+			return err
+		}
+	case CSV:
+		if o.n == 0 {
+			if _, err := o.w.WriteString("count,value\n"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return o.w.Flush()
+}
+
+// WriteAll outputs a complete list of primes, such as the contents of an
+// api.PrimeNumbers response, numbering them from one.
+func WriteAll(w io.Writer, format Format, primes []int64) error {
+	o, err := NewWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	for i, p := range primes {
+		if err := o.Write(int64(i+1), p); err != nil {
+			return err
+		}
+	}
+
+	return o.Close()
+}
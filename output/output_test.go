@@ -0,0 +1,52 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteAll(t *testing.T) {
+	tests := []struct {
+		format Format
+		primes []int64
+		want   string
+	}{
+		{Plain, []int64{2, 3, 5}, "2\n3\n5\n"},
+		{Plain, nil, ""},
+		{JSON, []int64{2, 3}, "[\n  {\"count\":1,\"value\":2},\n  {\"count\":2,\"value\":3}\n]\n"},
+		{JSON, nil, "[]\n"},
+		{NDJSON, []int64{2, 3}, "{\"count\":1,\"value\":2}\n{\"count\":2,\"value\":3}\n"},
+		{NDJSON, nil, ""},
+		{CSV, []int64{2, 3}, "count,value\n1,2\n2,3\n"},
+		{CSV, nil, "count,value\n"},
+	}
+
+	for _, tc := range tests {
+		var b bytes.Buffer
+		if err := WriteAll(&b, tc.format, tc.primes); err != nil {
+			t.Errorf("%s %v: %s", tc.format, tc.primes, err)
+			continue
+		}
+		if got := b.String(); got != tc.want {
+			t.Errorf("%s %v: got %q, want %q", tc.format, tc.primes, got, tc.want)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	for _, s := range []string{"log", "plain", "json", "ndjson", "csv"} {
+		if _, err := ParseFormat(s); err != nil {
+			t.Errorf("format %q rejected: %s", s, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("unknown format accepted")
+	}
+
+	// The log format is written by the clients themselves.
+	for _, f := range []Format{Log, "xml"} {
+		if _, err := NewWriter(&bytes.Buffer{}, f); err == nil {
+			t.Errorf("NewWriter accepted format %q", f)
+		}
+	}
+}
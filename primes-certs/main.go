@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/devries/grpc-tutorial/certs"
+)
+
+// This command creates the certificates used by the TLS examples, in place of
+// minica: a root certificate in minica.pem, a server certificate in
+// localhost/, and a client certificate in 127.0.0.1/. The root certificate is
+// reused if it already exists, so more certificates can be added later. It
+// also revokes certificates, listing them in crl.pem.
+func main() {
+	dir := flag.String("dir", ".", "directory to write the certificates to")
+	server := flag.String("server", "localhost", "comma separated host names and IP addresses of the server certificate (none if empty)")
+	client := flag.String("client", "127.0.0.1", "comma separated host names and IP addresses of the client certificate (none if empty)")
+	clientSubject := flag.String("client-subject", "", "common name of the client certificate (the first client name if empty)")
+	validity := flag.Duration("validity", 2*365*24*time.Hour, "how long the server and client certificates are valid for")
+	force := flag.Bool("force", false, "replace certificates which already exist")
+	revoke := flag.String("revoke", "", "comma separated certificate files to add to the revocation list")
+	crl := flag.Bool("crl", false, "write the revocation list even if no certificates are revoked, to create or renew it")
+	crlValidity := flag.Duration("crl-validity", 30*24*time.Hour, "how long the revocation list is valid for")
+
+	flag.Parse()
+
+	rootFile := filepath.Join(*dir, certs.RootCertFile)
+	_, statErr := os.Stat(rootFile)
+
+	ca, err := certs.LoadAuthority(*dir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && errors.Is(statErr, fs.ErrNotExist):
+		ca, err = certs.NewAuthority("primes-certs root ca", 100*365*24*time.Hour)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := ca.Save(*dir); err != nil {
+			log.Fatalf("could not write root certificate: %s", err)
+		}
+		log.Printf("Created root certificate %s", rootFile)
+	case err != nil:
+		log.Fatalf("could not load root certificate: %s", err)
+	default:
+		log.Printf("Using root certificate %s", rootFile)
+	}
+
+	if err := issue(ca, *dir, "", names(*server), *validity, *force); err != nil {
+		log.Fatalf("could not create server certificate: %s", err)
+	}
+	if err := issue(ca, *dir, *clientSubject, names(*client), *validity, *force); err != nil {
+		log.Fatalf("could not create client certificate: %s", err)
+	}
+
+	if *crl || *revoke != "" {
+		if err := revokeCertificates(ca, *dir, names(*revoke), *crlValidity); err != nil {
+			log.Fatalf("could not write revocation list: %s", err)
+		}
+	}
+}
+
+// names splits a comma separated list of names, dropping empty ones.
+func names(list string) []string {
+	var ns []string
+	for _, n := range strings.Split(list, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			ns = append(ns, n)
+		}
+	}
+	return ns
+}
+
+// issue writes a certificate for names to the directory named after the
+// first of them, unless it already exists and force is false.
+func issue(ca *certs.Authority, dir, subject string, names []string, validity time.Duration, force bool) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	certDir := filepath.Join(dir, names[0])
+	if _, err := os.Stat(filepath.Join(certDir, certs.CertFile)); err == nil && !force {
+		log.Printf("Keeping the certificate in %s (use -force to replace it)", certDir)
+		return nil
+	}
+
+	cert, err := ca.Issue(subject, names, validity)
+	if err != nil {
+		return err
+	}
+	if err := certs.Save(certDir, cert); err != nil {
+		return err
+	}
+
+	log.Printf("Created certificate for %s in %s", strings.Join(names, ", "), certDir)
+	return nil
+}
+
+// revokeCertificates adds the certificates in files to the revocation list
+// in dir, keeping those already on it, and writes a new list.
+func revokeCertificates(ca *certs.Authority, dir string, files []string, validity time.Duration) error {
+	path := filepath.Join(dir, certs.CRLFile)
+
+	var entries []x509.RevocationListEntry
+	bs, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		list, err := certs.ParseRevocationList(bs)
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", path, err)
+		}
+		if err := list.CheckSignatureFrom(ca.Certificate); err != nil {
+			return fmt.Errorf("%s was not signed by the root certificate: %w", path, err)
+		}
+		entries = list.RevokedCertificateEntries
+	}
+
+	listed := make(map[string]bool)
+	for _, entry := range entries {
+		listed[entry.SerialNumber.String()] = true
+	}
+
+	for _, file := range files {
+		bs, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		found, err := certs.ParseCertificates(bs)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		cert := found[0]
+		if err := cert.CheckSignatureFrom(ca.Certificate); err != nil {
+			return fmt.Errorf("%s was not issued by the root certificate", file)
+		}
+
+		if listed[cert.SerialNumber.String()] {
+			log.Printf("%s (%s) is already revoked", file, cert.Subject)
+			continue
+		}
+		listed[cert.SerialNumber.String()] = true
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: cert.SerialNumber, RevocationTime: time.Now()})
+		log.Printf("Revoked %s (%s)", file, cert.Subject)
+	}
+
+	der, err := ca.RevocationList(entries, validity)
+	if err != nil {
+		return err
+	}
+	if err := certs.SaveRevocationList(path, der); err != nil {
+		return err
+	}
+
+	log.Printf("Wrote revocation list %s listing %d certificates", path, len(entries))
+	return nil
+}
@@ -0,0 +1,3 @@
+package primesv1
+
+//go:generate protoc -I ../.. -I ../../third_party/googleapis --go_out=paths=source_relative:../.. --go-grpc_out=paths=source_relative:../.. --grpc-gateway_out=paths=source_relative:../.. --openapiv2_out=../.. --connect-go_out=paths=source_relative:../.. ../../primes/v1/primes.proto
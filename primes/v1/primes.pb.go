@@ -0,0 +1,331 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.1
+// 	protoc        (unknown)
+// source: primes/v1/primes.proto
+
+package primesv1
+
+import (
+	_ "github.com/devries/grpc-tutorial/validate"
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type PrimeCount struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The number of primes to return: at most 500 from GetPrimes, and at most
+	// ten million from StreamPrimes.
+	Number int64 `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`
+	// If the primes cannot all be found before the call's deadline, return
+	// those which were found instead of failing with DEADLINE_EXCEEDED. Only
+	// GetPrimes uses this.
+	AllowPartial bool `protobuf:"varint,2,opt,name=allow_partial,json=allowPartial,proto3" json:"allow_partial,omitempty"`
+}
+
+func (x *PrimeCount) Reset() {
+	*x = PrimeCount{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_primes_v1_primes_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PrimeCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrimeCount) ProtoMessage() {}
+
+func (x *PrimeCount) ProtoReflect() protoreflect.Message {
+	mi := &file_primes_v1_primes_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrimeCount.ProtoReflect.Descriptor instead.
+func (*PrimeCount) Descriptor() ([]byte, []int) {
+	return file_primes_v1_primes_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PrimeCount) GetNumber() int64 {
+	if x != nil {
+		return x.Number
+	}
+	return 0
+}
+
+func (x *PrimeCount) GetAllowPartial() bool {
+	if x != nil {
+		return x.AllowPartial
+	}
+	return false
+}
+
+type PrimeNumbers struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Contents []int64 `protobuf:"varint,1,rep,packed,name=contents,proto3" json:"contents,omitempty"`
+	// Set when allow_partial was requested and fewer primes than asked for
+	// are returned because the deadline was near.
+	Truncated bool `protobuf:"varint,2,opt,name=truncated,proto3" json:"truncated,omitempty"`
+}
+
+func (x *PrimeNumbers) Reset() {
+	*x = PrimeNumbers{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_primes_v1_primes_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PrimeNumbers) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrimeNumbers) ProtoMessage() {}
+
+func (x *PrimeNumbers) ProtoReflect() protoreflect.Message {
+	mi := &file_primes_v1_primes_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrimeNumbers.ProtoReflect.Descriptor instead.
+func (*PrimeNumbers) Descriptor() ([]byte, []int) {
+	return file_primes_v1_primes_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PrimeNumbers) GetContents() []int64 {
+	if x != nil {
+		return x.Contents
+	}
+	return nil
+}
+
+func (x *PrimeNumbers) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+type PrimeNumber struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The position of the prime, counting from one.
+	Count int64 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Value int64 `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *PrimeNumber) Reset() {
+	*x = PrimeNumber{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_primes_v1_primes_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PrimeNumber) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrimeNumber) ProtoMessage() {}
+
+func (x *PrimeNumber) ProtoReflect() protoreflect.Message {
+	mi := &file_primes_v1_primes_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrimeNumber.ProtoReflect.Descriptor instead.
+func (*PrimeNumber) Descriptor() ([]byte, []int) {
+	return file_primes_v1_primes_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PrimeNumber) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *PrimeNumber) GetValue() int64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+var File_primes_v1_primes_proto protoreflect.FileDescriptor
+
+var file_primes_v1_primes_proto_rawDesc = []byte{
+	0x0a, 0x16, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x73, 0x2f, 0x76, 0x31, 0x2f, 0x70, 0x72, 0x69, 0x6d,
+	0x65, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x09, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x73,
+	0x2e, 0x76, 0x31, 0x1a, 0x1c, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x61, 0x70, 0x69, 0x2f,
+	0x61, 0x6e, 0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x1a, 0x17, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x61, 0x74, 0x65, 0x2f, 0x76, 0x61, 0x6c, 0x69,
+	0x64, 0x61, 0x74, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x58, 0x0a, 0x0a, 0x50, 0x72,
+	0x69, 0x6d, 0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x25, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62,
+	0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x42, 0x0d, 0xa2, 0xbb, 0x18, 0x09, 0x0a, 0x07,
+	0x08, 0x00, 0x10, 0x80, 0xad, 0xe2, 0x04, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12,
+	0x23, 0x0a, 0x0d, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x61, 0x6c,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x50, 0x61, 0x72,
+	0x74, 0x69, 0x61, 0x6c, 0x22, 0x48, 0x0a, 0x0c, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x4e, 0x75, 0x6d,
+	0x62, 0x65, 0x72, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x03, 0x52, 0x08, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x73,
+	0x12, 0x1c, 0x0a, 0x09, 0x74, 0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x09, 0x74, 0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64, 0x22, 0x39,
+	0x0a, 0x0b, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x14, 0x0a,
+	0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x63, 0x6f,
+	0x75, 0x6e, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x32, 0xcf, 0x01, 0x0a, 0x0d, 0x50, 0x72,
+	0x69, 0x6d, 0x65, 0x73, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x62, 0x0a, 0x09, 0x47,
+	0x65, 0x74, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x73, 0x12, 0x15, 0x2e, 0x70, 0x72, 0x69, 0x6d, 0x65,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x1a,
+	0x17, 0x2e, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x69, 0x6d,
+	0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x22, 0x25, 0xaa, 0xbb, 0x18, 0x0f, 0x0a, 0x06,
+	0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x05, 0x0a, 0x03, 0x10, 0xf4, 0x03, 0x82, 0xd3, 0xe4,
+	0x93, 0x02, 0x0c, 0x12, 0x0a, 0x2f, 0x76, 0x31, 0x2f, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x73, 0x12,
+	0x5a, 0x0a, 0x0c, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x73, 0x12,
+	0x15, 0x2e, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x69, 0x6d,
+	0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x1a, 0x16, 0x2e, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x19,
+	0x82, 0xd3, 0xe4, 0x93, 0x02, 0x13, 0x12, 0x11, 0x2f, 0x76, 0x31, 0x2f, 0x70, 0x72, 0x69, 0x6d,
+	0x65, 0x73, 0x3a, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x30, 0x01, 0x42, 0x35, 0x5a, 0x33, 0x67,
+	0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64, 0x65, 0x76, 0x72, 0x69, 0x65,
+	0x73, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x2d, 0x74, 0x75, 0x74, 0x6f, 0x72, 0x69, 0x61, 0x6c, 0x2f,
+	0x70, 0x72, 0x69, 0x6d, 0x65, 0x73, 0x2f, 0x76, 0x31, 0x3b, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x73,
+	0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_primes_v1_primes_proto_rawDescOnce sync.Once
+	file_primes_v1_primes_proto_rawDescData = file_primes_v1_primes_proto_rawDesc
+)
+
+func file_primes_v1_primes_proto_rawDescGZIP() []byte {
+	file_primes_v1_primes_proto_rawDescOnce.Do(func() {
+		file_primes_v1_primes_proto_rawDescData = protoimpl.X.CompressGZIP(file_primes_v1_primes_proto_rawDescData)
+	})
+	return file_primes_v1_primes_proto_rawDescData
+}
+
+var file_primes_v1_primes_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_primes_v1_primes_proto_goTypes = []interface{}{
+	(*PrimeCount)(nil),   // 0: primes.v1.PrimeCount
+	(*PrimeNumbers)(nil), // 1: primes.v1.PrimeNumbers
+	(*PrimeNumber)(nil),  // 2: primes.v1.PrimeNumber
+}
+var file_primes_v1_primes_proto_depIdxs = []int32{
+	0, // 0: primes.v1.PrimesService.GetPrimes:input_type -> primes.v1.PrimeCount
+	0, // 1: primes.v1.PrimesService.StreamPrimes:input_type -> primes.v1.PrimeCount
+	1, // 2: primes.v1.PrimesService.GetPrimes:output_type -> primes.v1.PrimeNumbers
+	2, // 3: primes.v1.PrimesService.StreamPrimes:output_type -> primes.v1.PrimeNumber
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_primes_v1_primes_proto_init() }
+func file_primes_v1_primes_proto_init() {
+	if File_primes_v1_primes_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_primes_v1_primes_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PrimeCount); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_primes_v1_primes_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PrimeNumbers); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_primes_v1_primes_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PrimeNumber); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_primes_v1_primes_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_primes_v1_primes_proto_goTypes,
+		DependencyIndexes: file_primes_v1_primes_proto_depIdxs,
+		MessageInfos:      file_primes_v1_primes_proto_msgTypes,
+	}.Build()
+	File_primes_v1_primes_proto = out.File
+	file_primes_v1_primes_proto_rawDesc = nil
+	file_primes_v1_primes_proto_goTypes = nil
+	file_primes_v1_primes_proto_depIdxs = nil
+}
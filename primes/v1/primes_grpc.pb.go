@@ -0,0 +1,179 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: primes/v1/primes.proto
+
+package primesv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PrimesService_GetPrimes_FullMethodName    = "/primes.v1.PrimesService/GetPrimes"
+	PrimesService_StreamPrimes_FullMethodName = "/primes.v1.PrimesService/StreamPrimes"
+)
+
+// PrimesServiceClient is the client API for PrimesService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// PrimesService returns the first primes, either all at once or streamed one
+// at a time as they are found. It replaces the unversioned api.Primes and
+// apistream.PrimeStream services, which remain available for existing
+// clients.
+type PrimesServiceClient interface {
+	// Served over HTTP by the REST gateway as GET /v1/primes?number=5.
+	GetPrimes(ctx context.Context, in *PrimeCount, opts ...grpc.CallOption) (*PrimeNumbers, error)
+	// Served over HTTP by the REST gateway as GET /v1/primes:stream?number=5,
+	// which returns one JSON object per line as the primes are found.
+	StreamPrimes(ctx context.Context, in *PrimeCount, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PrimeNumber], error)
+}
+
+type primesServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPrimesServiceClient(cc grpc.ClientConnInterface) PrimesServiceClient {
+	return &primesServiceClient{cc}
+}
+
+func (c *primesServiceClient) GetPrimes(ctx context.Context, in *PrimeCount, opts ...grpc.CallOption) (*PrimeNumbers, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PrimeNumbers)
+	err := c.cc.Invoke(ctx, PrimesService_GetPrimes_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *primesServiceClient) StreamPrimes(ctx context.Context, in *PrimeCount, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PrimeNumber], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PrimesService_ServiceDesc.Streams[0], PrimesService_StreamPrimes_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PrimeCount, PrimeNumber]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PrimesService_StreamPrimesClient = grpc.ServerStreamingClient[PrimeNumber]
+
+// PrimesServiceServer is the server API for PrimesService service.
+// All implementations must embed UnimplementedPrimesServiceServer
+// for forward compatibility.
+//
+// PrimesService returns the first primes, either all at once or streamed one
+// at a time as they are found. It replaces the unversioned api.Primes and
+// apistream.PrimeStream services, which remain available for existing
+// clients.
+type PrimesServiceServer interface {
+	// Served over HTTP by the REST gateway as GET /v1/primes?number=5.
+	GetPrimes(context.Context, *PrimeCount) (*PrimeNumbers, error)
+	// Served over HTTP by the REST gateway as GET /v1/primes:stream?number=5,
+	// which returns one JSON object per line as the primes are found.
+	StreamPrimes(*PrimeCount, grpc.ServerStreamingServer[PrimeNumber]) error
+	mustEmbedUnimplementedPrimesServiceServer()
+}
+
+// UnimplementedPrimesServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPrimesServiceServer struct{}
+
+func (UnimplementedPrimesServiceServer) GetPrimes(context.Context, *PrimeCount) (*PrimeNumbers, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPrimes not implemented")
+}
+func (UnimplementedPrimesServiceServer) StreamPrimes(*PrimeCount, grpc.ServerStreamingServer[PrimeNumber]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamPrimes not implemented")
+}
+func (UnimplementedPrimesServiceServer) mustEmbedUnimplementedPrimesServiceServer() {}
+func (UnimplementedPrimesServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafePrimesServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PrimesServiceServer will
+// result in compilation errors.
+type UnsafePrimesServiceServer interface {
+	mustEmbedUnimplementedPrimesServiceServer()
+}
+
+func RegisterPrimesServiceServer(s grpc.ServiceRegistrar, srv PrimesServiceServer) {
+	// If the following call pancis, it indicates UnimplementedPrimesServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PrimesService_ServiceDesc, srv)
+}
+
+func _PrimesService_GetPrimes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrimeCount)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PrimesServiceServer).GetPrimes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PrimesService_GetPrimes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PrimesServiceServer).GetPrimes(ctx, req.(*PrimeCount))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PrimesService_StreamPrimes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PrimeCount)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PrimesServiceServer).StreamPrimes(m, &grpc.GenericServerStream[PrimeCount, PrimeNumber]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PrimesService_StreamPrimesServer = grpc.ServerStreamingServer[PrimeNumber]
+
+// PrimesService_ServiceDesc is the grpc.ServiceDesc for PrimesService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PrimesService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "primes.v1.PrimesService",
+	HandlerType: (*PrimesServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetPrimes",
+			Handler:    _PrimesService_GetPrimes_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamPrimes",
+			Handler:       _PrimesService_StreamPrimes_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "primes/v1/primes.proto",
+}
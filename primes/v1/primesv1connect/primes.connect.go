@@ -0,0 +1,148 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: primes/v1/primes.proto
+
+package primesv1connect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	errors "errors"
+	v1 "github.com/devries/grpc-tutorial/primes/v1"
+	http "net/http"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// PrimesServiceName is the fully-qualified name of the PrimesService service.
+	PrimesServiceName = "primes.v1.PrimesService"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// PrimesServiceGetPrimesProcedure is the fully-qualified name of the PrimesService's GetPrimes RPC.
+	PrimesServiceGetPrimesProcedure = "/primes.v1.PrimesService/GetPrimes"
+	// PrimesServiceStreamPrimesProcedure is the fully-qualified name of the PrimesService's
+	// StreamPrimes RPC.
+	PrimesServiceStreamPrimesProcedure = "/primes.v1.PrimesService/StreamPrimes"
+)
+
+// These variables are the protoreflect.Descriptor objects for the RPCs defined in this package.
+var (
+	primesServiceServiceDescriptor            = v1.File_primes_v1_primes_proto.Services().ByName("PrimesService")
+	primesServiceGetPrimesMethodDescriptor    = primesServiceServiceDescriptor.Methods().ByName("GetPrimes")
+	primesServiceStreamPrimesMethodDescriptor = primesServiceServiceDescriptor.Methods().ByName("StreamPrimes")
+)
+
+// PrimesServiceClient is a client for the primes.v1.PrimesService service.
+type PrimesServiceClient interface {
+	// Served over HTTP by the REST gateway as GET /v1/primes?number=5.
+	GetPrimes(context.Context, *connect.Request[v1.PrimeCount]) (*connect.Response[v1.PrimeNumbers], error)
+	// Served over HTTP by the REST gateway as GET /v1/primes:stream?number=5,
+	// which returns one JSON object per line as the primes are found.
+	StreamPrimes(context.Context, *connect.Request[v1.PrimeCount]) (*connect.ServerStreamForClient[v1.PrimeNumber], error)
+}
+
+// NewPrimesServiceClient constructs a client for the primes.v1.PrimesService service. By default,
+// it uses the Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and
+// sends uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC()
+// or connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewPrimesServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) PrimesServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &primesServiceClient{
+		getPrimes: connect.NewClient[v1.PrimeCount, v1.PrimeNumbers](
+			httpClient,
+			baseURL+PrimesServiceGetPrimesProcedure,
+			connect.WithSchema(primesServiceGetPrimesMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+		streamPrimes: connect.NewClient[v1.PrimeCount, v1.PrimeNumber](
+			httpClient,
+			baseURL+PrimesServiceStreamPrimesProcedure,
+			connect.WithSchema(primesServiceStreamPrimesMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// primesServiceClient implements PrimesServiceClient.
+type primesServiceClient struct {
+	getPrimes    *connect.Client[v1.PrimeCount, v1.PrimeNumbers]
+	streamPrimes *connect.Client[v1.PrimeCount, v1.PrimeNumber]
+}
+
+// GetPrimes calls primes.v1.PrimesService.GetPrimes.
+func (c *primesServiceClient) GetPrimes(ctx context.Context, req *connect.Request[v1.PrimeCount]) (*connect.Response[v1.PrimeNumbers], error) {
+	return c.getPrimes.CallUnary(ctx, req)
+}
+
+// StreamPrimes calls primes.v1.PrimesService.StreamPrimes.
+func (c *primesServiceClient) StreamPrimes(ctx context.Context, req *connect.Request[v1.PrimeCount]) (*connect.ServerStreamForClient[v1.PrimeNumber], error) {
+	return c.streamPrimes.CallServerStream(ctx, req)
+}
+
+// PrimesServiceHandler is an implementation of the primes.v1.PrimesService service.
+type PrimesServiceHandler interface {
+	// Served over HTTP by the REST gateway as GET /v1/primes?number=5.
+	GetPrimes(context.Context, *connect.Request[v1.PrimeCount]) (*connect.Response[v1.PrimeNumbers], error)
+	// Served over HTTP by the REST gateway as GET /v1/primes:stream?number=5,
+	// which returns one JSON object per line as the primes are found.
+	StreamPrimes(context.Context, *connect.Request[v1.PrimeCount], *connect.ServerStream[v1.PrimeNumber]) error
+}
+
+// NewPrimesServiceHandler builds an HTTP handler from the service implementation. It returns the
+// path on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewPrimesServiceHandler(svc PrimesServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	primesServiceGetPrimesHandler := connect.NewUnaryHandler(
+		PrimesServiceGetPrimesProcedure,
+		svc.GetPrimes,
+		connect.WithSchema(primesServiceGetPrimesMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	primesServiceStreamPrimesHandler := connect.NewServerStreamHandler(
+		PrimesServiceStreamPrimesProcedure,
+		svc.StreamPrimes,
+		connect.WithSchema(primesServiceStreamPrimesMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/primes.v1.PrimesService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case PrimesServiceGetPrimesProcedure:
+			primesServiceGetPrimesHandler.ServeHTTP(w, r)
+		case PrimesServiceStreamPrimesProcedure:
+			primesServiceStreamPrimesHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedPrimesServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedPrimesServiceHandler struct{}
+
+func (UnimplementedPrimesServiceHandler) GetPrimes(context.Context, *connect.Request[v1.PrimeCount]) (*connect.Response[v1.PrimeNumbers], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("primes.v1.PrimesService.GetPrimes is not implemented"))
+}
+
+func (UnimplementedPrimesServiceHandler) StreamPrimes(context.Context, *connect.Request[v1.PrimeCount], *connect.ServerStream[v1.PrimeNumber]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("primes.v1.PrimesService.StreamPrimes is not implemented"))
+}
@@ -0,0 +1,350 @@
+// Package quota charges clients for each prime streamed to them against
+// hourly and daily budgets. Usage is kept per client identity, as used for
+// rate limiting, and saved to a local JSON file so that it survives restarts.
+//
+// When a stream asks for some number of primes that many are reserved from
+// the budgets, and any not sent are refunded when the stream ends. Requests
+// which would exceed a budget fail with ResourceExhausted. The remaining
+// budgets are reported in the x-quota-remaining-hourly and
+// x-quota-remaining-daily response headers and trailers.
+package quota
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/devries/grpc-tutorial/internal/primesmsg"
+	"github.com/devries/grpc-tutorial/ratelimit"
+	"github.com/devries/grpc-tutorial/richstatus"
+)
+
+// Metadata keys reporting the remaining budgets.
+const (
+	RemainingHourlyKey = "x-quota-remaining-hourly"
+	RemainingDailyKey  = "x-quota-remaining-daily"
+)
+
+// DefaultFile is where usage is saved if QUOTA_FILE is unset.
+const DefaultFile = "quota.json"
+
+// Manager tracks how many primes each client has been sent this hour and
+// this day. A budget of zero is unlimited.
+type Manager struct {
+	Hourly int64
+	Daily  int64
+	Key    ratelimit.KeyFunc
+
+	path  string
+	mu    sync.Mutex
+	usage map[string]*usage
+	now   func() time.Time
+}
+
+// usage is what one client has used in the current hour and day, both
+// measured in UTC.
+type usage struct {
+	Hour     time.Time `json:"hour"`
+	HourUsed int64     `json:"hour_used"`
+	Day      time.Time `json:"day"`
+	DayUsed  int64     `json:"day_used"`
+}
+
+// Open returns a manager with the given budgets for each client identified
+// by key, loading any usage saved in the file at path.
+func Open(path string, hourly, daily int64, key ratelimit.KeyFunc) (*Manager, error) {
+	m := &Manager{
+		Hourly: hourly,
+		Daily:  daily,
+		Key:    key,
+		path:   path,
+		usage:  make(map[string]*usage),
+		now:    time.Now,
+	}
+
+	bs, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read quota file: %w", err)
+	}
+	if err := json.Unmarshal(bs, &m.usage); err != nil {
+		return nil, fmt.Errorf("could not parse quota file %s: %w", path, err)
+	}
+
+	return m, nil
+}
+
+// FromEnv returns a manager configured by the QUOTA_HOURLY and QUOTA_DAILY
+// (primes per client), QUOTA_KEY ("peer" or "cert"), and QUOTA_FILE
+// environment variables. It returns nil if neither budget is set. Clients are
+// not keyed by token, since the streaming server does not check tokens and a
+// client could claim a new budget with every call.
+func FromEnv() (*Manager, error) {
+	hourly, err := budgetFromEnv("QUOTA_HOURLY")
+	if err != nil {
+		return nil, err
+	}
+	daily, err := budgetFromEnv("QUOTA_DAILY")
+	if err != nil {
+		return nil, err
+	}
+	if hourly == 0 && daily == 0 {
+		return nil, nil
+	}
+
+	key, err := ratelimit.ParseKey(os.Getenv("QUOTA_KEY"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("QUOTA_KEY %s", err)
+	}
+
+	path := os.Getenv("QUOTA_FILE")
+	if path == "" {
+		path = DefaultFile
+	}
+
+	return Open(path, hourly, daily, key)
+}
+
+func budgetFromEnv(name string) (int64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, not %q", name, v)
+	}
+	return n, nil
+}
+
+// Remaining is what is left of a client's budgets. Budgets which are not
+// limited are left out of the metadata.
+type Remaining struct {
+	Hourly int64
+	Daily  int64
+}
+
+func (m *Manager) metadata(r Remaining) metadata.MD {
+	md := metadata.MD{}
+	if m.Hourly > 0 {
+		md.Set(RemainingHourlyKey, strconv.FormatInt(r.Hourly, 10))
+	}
+	if m.Daily > 0 {
+		md.Set(RemainingDailyKey, strconv.FormatInt(r.Daily, 10))
+	}
+	return md
+}
+
+// current returns the usage of key, starting new windows if the hour or day
+// has changed since it was last used.
+func (m *Manager) current(key string, now time.Time) *usage {
+	hour := now.UTC().Truncate(time.Hour)
+	y, mo, d := now.UTC().Date()
+	day := time.Date(y, mo, d, 0, 0, 0, 0, time.UTC)
+
+	u, ok := m.usage[key]
+	if !ok {
+		u = &usage{}
+		m.usage[key] = u
+	}
+	if !u.Hour.Equal(hour) {
+		u.Hour = hour
+		u.HourUsed = 0
+	}
+	if !u.Day.Equal(day) {
+		u.Day = day
+		u.DayUsed = 0
+	}
+
+	return u
+}
+
+func (m *Manager) remaining(u *usage) Remaining {
+	return Remaining{Hourly: m.Hourly - u.HourUsed, Daily: m.Daily - u.DayUsed}
+}
+
+// Reservation is a number of primes taken from the budgets of a client, and
+// the hour and day whose budgets they were taken from.
+type Reservation struct {
+	Key  string
+	N    int64
+	Hour time.Time
+	Day  time.Time
+}
+
+// Reserve takes n primes from the budgets of key. If either budget does not
+// have n left nothing is taken, and the error is ResourceExhausted along
+// with how long until the budget is renewed.
+func (m *Manager) Reserve(key string, n int64) (Reservation, Remaining, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	u := m.current(key, now)
+	r := m.remaining(u)
+
+	if m.Hourly > 0 && n > r.Hourly {
+		wait := u.Hour.Add(time.Hour).Sub(now)
+		msg := fmt.Sprintf("hourly quota exceeded, %d primes requested but %d remain", n, r.Hourly)
+		return Reservation{}, r, wait, richstatus.QuotaExceeded(key, msg, wait)
+	}
+	if m.Daily > 0 && n > r.Daily {
+		wait := u.Day.AddDate(0, 0, 1).Sub(now)
+		msg := fmt.Sprintf("daily quota exceeded, %d primes requested but %d remain", n, r.Daily)
+		return Reservation{}, r, wait, richstatus.QuotaExceeded(key, msg, wait)
+	}
+
+	u.HourUsed += n
+	u.DayUsed += n
+	res := Reservation{Key: key, N: n, Hour: u.Hour, Day: u.Day}
+	return res, m.remaining(u), 0, nil
+}
+
+// Release returns unused primes of a reservation to the budgets they were
+// taken from, and saves the usage of every client. Nothing is returned to a
+// budget which has been renewed since the reservation was made, as that
+// would take from the new window's usage.
+func (m *Manager) Release(res Reservation, unused int64) Remaining {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	u := m.current(res.Key, now)
+	unused = min(unused, res.N)
+	if u.Hour.Equal(res.Hour) {
+		u.HourUsed = max(u.HourUsed-unused, 0)
+	}
+	if u.Day.Equal(res.Day) {
+		u.DayUsed = max(u.DayUsed-unused, 0)
+	}
+
+	if err := m.save(now); err != nil {
+		slog.Warn("Could not save quota usage", "file", m.path, "error", err)
+	}
+
+	return m.remaining(u)
+}
+
+// save writes the usage to a temporary file and renames it into place, so
+// that a crash never leaves a partly written file. Clients with no usage in
+// the current day are dropped.
+func (m *Manager) save(now time.Time) error {
+	for k, u := range m.usage {
+		if now.Sub(u.Day) >= 24*time.Hour {
+			delete(m.usage, k)
+		}
+	}
+
+	bs, err := json.Marshal(m.usage)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(m.path), filepath.Base(m.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(bs); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), m.path)
+}
+
+// ServerOptions returns the interceptor which charges streams against the
+// budgets. A nil manager returns no options.
+func (m *Manager) ServerOptions() []grpc.ServerOption {
+	if m == nil {
+		return nil
+	}
+
+	return []grpc.ServerOption{grpc.ChainStreamInterceptor(m.StreamServerInterceptor)}
+}
+
+// StreamServerInterceptor reserves the primes asked for by each request,
+// refunds those which were not sent, and reports the remaining budgets.
+func (m *Manager) StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	qs := &quotaStream{ServerStream: ss, m: m, key: m.Key(ss.Context())}
+	err := handler(srv, qs)
+
+	if len(qs.reservations) > 0 {
+		ss.SetTrailer(m.metadata(qs.release()))
+	}
+
+	return err
+}
+
+// quotaStream makes a reservation when each request arrives and counts the
+// primes sent.
+type quotaStream struct {
+	grpc.ServerStream
+	m            *Manager
+	key          string
+	reservations []Reservation
+	sent         int64
+}
+
+// release refunds the primes which were not sent. Those sent are charged to
+// the earliest reservations, so the unused primes belong to the latest.
+func (s *quotaStream) release() Remaining {
+	sent := s.sent
+	var r Remaining
+	for _, res := range s.reservations {
+		used := min(sent, res.N)
+		sent -= used
+		r = s.m.Release(res, res.N-used)
+	}
+	return r
+}
+
+func (s *quotaStream) RecvMsg(msg interface{}) error {
+	if err := s.ServerStream.RecvMsg(msg); err != nil {
+		return err
+	}
+
+	n, ok := msg.(primesmsg.Number)
+	if !ok || n.GetNumber() <= 0 {
+		return nil
+	}
+
+	res, r, wait, err := s.m.Reserve(s.key, n.GetNumber())
+	md := s.m.metadata(r)
+	if err != nil {
+		seconds := int64(math.Ceil(wait.Seconds()))
+		md.Set(ratelimit.RetryAfterTrailer, strconv.FormatInt(seconds, 10))
+		s.SetTrailer(md)
+		slog.WarnContext(s.Context(), "Quota exceeded", "requested", n.GetNumber(), "error", status.Convert(err).Message())
+		return err
+	}
+
+	s.reservations = append(s.reservations, res)
+	return s.SetHeader(md)
+}
+
+func (s *quotaStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if _, ok := msg.(primesmsg.Value); ok && err == nil {
+		s.sent++
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package quota
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/devries/grpc-tutorial/ratelimit"
+)
+
+// open returns a manager saving usage to path, whose clock reads *now, so
+// that it moves only when the test changes it.
+func open(t *testing.T, path string, hourly, daily int64, now *time.Time) *Manager {
+	t.Helper()
+
+	m, err := Open(path, hourly, daily, ratelimit.ByPeerIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return *now }
+	return m
+}
+
+func expectRemaining(t *testing.T, got Remaining, hourly, daily int64) {
+	t.Helper()
+	if got.Hourly != hourly || got.Daily != daily {
+		t.Errorf("remaining %d hourly, %d daily, want %d, %d", got.Hourly, got.Daily, hourly, daily)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 15, 0, 0, time.UTC)
+	m := open(t, filepath.Join(t.TempDir(), "quota.json"), 100, 250, &now)
+
+	_, r, _, err := m.Reserve("a", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRemaining(t, r, 40, 190)
+
+	// Clients have budgets of their own.
+	_, r, _, err = m.Reserve("b", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRemaining(t, r, 0, 150)
+
+	// The hourly budget is renewed on the hour, the daily one is not.
+	now = now.Add(time.Hour)
+	_, r, _, err = m.Reserve("a", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRemaining(t, r, 0, 90)
+}
+
+func TestExceeded(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 15, 0, 0, time.UTC)
+	m := open(t, filepath.Join(t.TempDir(), "quota.json"), 100, 150, &now)
+
+	if _, _, _, err := m.Reserve("a", 80); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is taken by a reservation which would exceed a budget.
+	_, r, wait, err := m.Reserve("a", 30)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("got %v, want ResourceExhausted", err)
+	}
+	expectRemaining(t, r, 20, 70)
+	if wait != 45*time.Minute {
+		t.Errorf("wait %s, want 45m until the next hour", wait)
+	}
+
+	now = now.Add(time.Hour)
+	_, _, wait, err = m.Reserve("a", 80)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("got %v, want ResourceExhausted", err)
+	}
+	if want := 12*time.Hour + 45*time.Minute; wait != want {
+		t.Errorf("wait %s, want %s until the next day", wait, want)
+	}
+}
+
+func TestRelease(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 50, 0, 0, time.UTC)
+	m := open(t, filepath.Join(t.TempDir(), "quota.json"), 100, 250, &now)
+
+	res, _, _, err := m.Reserve("a", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRemaining(t, m.Release(res, 50), 90, 240)
+
+	// A stream reserved in one hour and ending in the next is refunded to
+	// the day only, as the hour it was charged to is over.
+	res, _, _, err = m.Reserve("a", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(20 * time.Minute)
+	if _, _, _, err := m.Reserve("a", 30); err != nil {
+		t.Fatal(err)
+	}
+	expectRemaining(t, m.Release(res, 60), 70, 210)
+
+	// Nothing is refunded once the day is over too.
+	res, _, _, err = m.Reserve("a", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(24 * time.Hour)
+	expectRemaining(t, m.Release(res, 20), 100, 250)
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	now := time.Date(2024, 5, 1, 10, 15, 0, 0, time.UTC)
+	m := open(t, path, 100, 250, &now)
+
+	res, _, _, err := m.Reserve("a", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Usage is saved when a reservation is released.
+	m.Release(res, 10)
+
+	m = open(t, path, 100, 250, &now)
+	_, r, _, err := m.Reserve("a", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRemaining(t, r, 40, 190)
+
+	// A client whose usage is from an earlier day is forgotten.
+	now = now.Add(24 * time.Hour)
+	res, _, _, err = m.Reserve("b", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Release(res, 0)
+
+	m = open(t, path, 100, 250, &now)
+	if _, ok := m.usage["a"]; ok {
+		t.Error("usage of a is still saved the next day")
+	}
+	if u := m.usage["b"]; u == nil || u.DayUsed != 5 {
+		t.Errorf("usage of b is %+v, want 5 used", u)
+	}
+}
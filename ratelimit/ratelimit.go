@@ -0,0 +1,271 @@
+// Package ratelimit limits how often each client may call the servers, using
+// a token bucket per client. Clients are identified by their bearer token,
+// their client certificate subject, or their IP address. Calls over the limit
+// fail with ResourceExhausted and a retry-after trailer giving the number of
+// seconds to wait before trying again.
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/devries/grpc-tutorial/richstatus"
+)
+
+// RetryAfterTrailer is the trailer which tells a limited client how many
+// seconds to wait.
+const RetryAfterTrailer = "retry-after"
+
+// KeyFunc returns the identity of the client making a call.
+type KeyFunc func(ctx context.Context) string
+
+// ByPeerIP identifies clients by their IP address.
+func ByPeerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "ip:unknown"
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return "ip:" + host
+}
+
+// ByCertificate identifies clients by the subject of their client
+// certificate, falling back to their IP address.
+func ByCertificate(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsAuth, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsAuth.State.VerifiedChains) > 0 {
+			return "cert:" + tlsAuth.State.VerifiedChains[0][0].Subject.String()
+		}
+	}
+	return ByPeerIP(ctx)
+}
+
+// TokenValidator reports whether the value of an authorization header, such
+// as "Bearer HelloWorld", carries a token the server accepts.
+type TokenValidator func(authorization string) bool
+
+// ByToken identifies clients by their bearer token, if valid says it is one
+// the server accepts, and otherwise by their IP address. Only a hash of the
+// token is kept. Tokens are checked first so that a client cannot escape its
+// limit by sending a different made up token with each call.
+func ByToken(valid TokenValidator) KeyFunc {
+	return func(ctx context.Context) string {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if tokens := md.Get("authorization"); len(tokens) > 0 && valid(tokens[0]) {
+				sum := sha256.Sum256([]byte(tokens[0]))
+				return "token:" + hex.EncodeToString(sum[:8])
+			}
+		}
+		return ByPeerIP(ctx)
+	}
+}
+
+// Limiter keeps a token bucket for each client. Each bucket holds up to
+// Burst tokens and refills at Rate tokens per second, and every call takes
+// one token.
+type Limiter struct {
+	Rate  float64
+	Burst float64
+	Key   KeyFunc
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a limiter allowing rate calls per second, with bursts
+// of up to burst calls, for each client identified by key.
+func NewLimiter(rate float64, burst int, key KeyFunc) *Limiter {
+	return &Limiter{
+		Rate:    rate,
+		Burst:   float64(burst),
+		Key:     key,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// FromEnv returns a limiter configured by the RATE_LIMIT (calls per second),
+// RATE_LIMIT_BURST, and RATE_LIMIT_KEY ("peer", "cert", or "token")
+// environment variables. validToken checks the tokens of servers which
+// authenticate clients with them, and is nil for other servers. It returns
+// nil if RATE_LIMIT is unset or zero.
+func FromEnv(validToken TokenValidator) (*Limiter, error) {
+	v := os.Getenv("RATE_LIMIT")
+	if v == "" {
+		return nil, nil
+	}
+	rate, err := strconv.ParseFloat(v, 64)
+	if err != nil || rate < 0 {
+		return nil, fmt.Errorf("RATE_LIMIT must be a non-negative number, not %q", v)
+	}
+	if rate == 0 {
+		return nil, nil
+	}
+
+	burst := int(math.Ceil(rate))
+	if v := os.Getenv("RATE_LIMIT_BURST"); v != "" {
+		burst, err = strconv.Atoi(v)
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("RATE_LIMIT_BURST must be a positive integer, not %q", v)
+		}
+	}
+
+	key, err := ParseKey(os.Getenv("RATE_LIMIT_KEY"), validToken)
+	if err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_KEY %s", err)
+	}
+
+	return NewLimiter(rate, burst, key), nil
+}
+
+// ParseKey returns the KeyFunc named by s: "peer" (the default when s is
+// empty), "cert", or "token". Tokens are checked with validToken, and
+// "token" is refused if it is nil, since a server which does not check
+// tokens cannot tell real clients from made up ones.
+func ParseKey(s string, validToken TokenValidator) (KeyFunc, error) {
+	switch strings.ToLower(s) {
+	case "", "peer":
+		return ByPeerIP, nil
+	case "cert":
+		return ByCertificate, nil
+	case "token":
+		if validToken == nil {
+			return nil, errors.New("cannot be token on a server which does not check tokens")
+		}
+		return ByToken(validToken), nil
+	}
+	return nil, fmt.Errorf("must be peer, cert, or token, not %q", s)
+}
+
+// Allow takes a token from the bucket for key. If none is available it
+// returns false and how long to wait until one will be.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.Burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.Burst, b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep forgets buckets which have refilled completely, so the map does not
+// grow with every client ever seen. It runs at most once a minute.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	full := time.Duration(l.Burst / l.Rate * float64(time.Second))
+	for k, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// exempt reports whether calls to fullMethod are never limited. Health
+// checks come from load balancers and probes, which must not be turned away.
+func exempt(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/grpc.health.v1.Health/")
+}
+
+// check returns a ResourceExhausted error and the retry-after trailer if the
+// client has exceeded its limit.
+func (l *Limiter) check(ctx context.Context, fullMethod string) (metadata.MD, error) {
+	if exempt(fullMethod) {
+		return nil, nil
+	}
+
+	key := l.Key(ctx)
+	ok, wait := l.Allow(key)
+	if ok {
+		return nil, nil
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	trailer := metadata.Pairs(RetryAfterTrailer, strconv.Itoa(seconds))
+	msg := fmt.Sprintf("rate limit exceeded, retry after %d seconds", seconds)
+
+	return trailer, richstatus.QuotaExceeded(key, msg, wait)
+}
+
+// ServerOptions returns the interceptors which apply the limiter to unary
+// and streaming calls. A nil limiter returns no options.
+func (l *Limiter) ServerOptions() []grpc.ServerOption {
+	if l == nil {
+		return nil
+	}
+
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(l.UnaryServerInterceptor),
+		grpc.ChainStreamInterceptor(l.StreamServerInterceptor),
+	}
+}
+
+// UnaryServerInterceptor limits unary calls.
+func (l *Limiter) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	trailer, err := l.check(ctx, info.FullMethod)
+	if err != nil {
+		grpc.SetTrailer(ctx, trailer)
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// StreamServerInterceptor limits streaming calls.
+func (l *Limiter) StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	trailer, err := l.check(ss.Context(), info.FullMethod)
+	if err != nil {
+		ss.SetTrailer(trailer)
+		return err
+	}
+
+	return handler(srv, ss)
+}
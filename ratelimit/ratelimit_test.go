@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const method = "/primes.v1.PrimesService/GetPrimes"
+
+func validHelloWorld(v string) bool { return v == "Bearer HelloWorld" }
+
+// callContext returns the context of a call from ip with the given
+// authorization header, if not empty.
+func callContext(ip, authorization string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 1234}})
+	if authorization != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", authorization))
+	}
+	return ctx
+}
+
+func randomToken(t *testing.T) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + hex.EncodeToString(b)
+}
+
+func TestByToken(t *testing.T) {
+	key := ByToken(validHelloWorld)
+
+	valid := key(callContext("10.0.0.1", "Bearer HelloWorld"))
+	if valid == ByPeerIP(callContext("10.0.0.1", "")) {
+		t.Errorf("valid token keyed by IP address: %s", valid)
+	}
+	if other := key(callContext("10.0.0.2", "Bearer HelloWorld")); other != valid {
+		t.Errorf("same token from another address keyed as %s, want %s", other, valid)
+	}
+
+	// Tokens the server does not accept are keyed by IP address.
+	for i := 0; i < 3; i++ {
+		if got, want := key(callContext("10.0.0.1", randomToken(t))), "ip:10.0.0.1"; got != want {
+			t.Errorf("invalid token keyed as %s, want %s", got, want)
+		}
+	}
+}
+
+// A client sending a different made up token with every call is limited as
+// one client.
+func TestRandomTokensShareBucket(t *testing.T) {
+	l := NewLimiter(1, 2, ByToken(validHelloWorld))
+	call := func(ctx context.Context) error {
+		_, err := l.check(ctx, method)
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := call(callContext("10.0.0.1", randomToken(t))); err != nil {
+			t.Fatalf("call %d limited: %s", i+1, err)
+		}
+	}
+	err := call(callContext("10.0.0.1", randomToken(t)))
+	if code := status.Code(err); code != codes.ResourceExhausted {
+		t.Errorf("third call got %s (%v), want %s", code, err, codes.ResourceExhausted)
+	}
+
+	// A client with a valid token has its own bucket.
+	if err := call(callContext("10.0.0.1", "Bearer HelloWorld")); err != nil {
+		t.Errorf("client with valid token limited: %s", err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	if _, err := ParseKey("token", nil); err == nil {
+		t.Error("token accepted without a validator")
+	}
+	if _, err := ParseKey("token", validHelloWorld); err != nil {
+		t.Error(err)
+	}
+	for _, s := range []string{"", "peer", "cert"} {
+		if _, err := ParseKey(s, nil); err != nil {
+			t.Errorf("%q: %s", s, err)
+		}
+	}
+	if _, err := ParseKey("user", nil); err == nil {
+		t.Error("unknown key accepted")
+	}
+}
@@ -0,0 +1,314 @@
+// Package retry configures how the Go clients retry failed calls. Retries use
+// the retry policy built into gRPC, configured through the service config,
+// while hedged requests are sent by a client interceptor.
+package retry
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Policy describes how failed calls are retried or hedged.
+type Policy struct {
+	// MaxAttempts is the total number of attempts, including the first. gRPC
+	// limits this to 5. A value of 1 or less disables retries.
+	MaxAttempts int
+	// InitialBackoff is the upper bound of the randomized delay before the
+	// first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the randomized delay between retries.
+	MaxBackoff time.Duration
+	// BackoffMultiplier scales the backoff after each retry.
+	BackoffMultiplier float64
+	// RetryableCodes are the status codes which cause a call to be retried.
+	// For hedged requests these are the codes which do not stop the other
+	// outstanding attempts.
+	RetryableCodes []codes.Code
+	// HedgingDelay enables hedged requests when it is greater than zero. A new
+	// attempt is started each time HedgingDelay passes without a response,
+	// up to MaxAttempts, and the first successful response is used. Hedging
+	// replaces retries, and only unary calls are hedged, so streaming calls
+	// are then not retried at all.
+	HedgingDelay time.Duration
+}
+
+// DefaultPolicy retries unavailable servers up to four times with
+// exponential backoff.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:       4,
+		InitialBackoff:    100 * time.Millisecond,
+		MaxBackoff:        2 * time.Second,
+		BackoffMultiplier: 2.0,
+		RetryableCodes:    []codes.Code{codes.Unavailable},
+	}
+}
+
+// Flags registers command line flags for the retry policy, starting from the
+// DefaultPolicy, and returns the policy they set.
+func Flags() *Policy {
+	p := DefaultPolicy()
+
+	flag.IntVar(&p.MaxAttempts, "retry-attempts", p.MaxAttempts, "maximum number of attempts for each call (1 disables retries)")
+	flag.DurationVar(&p.InitialBackoff, "retry-backoff", p.InitialBackoff, "initial retry backoff")
+	flag.DurationVar(&p.MaxBackoff, "retry-max-backoff", p.MaxBackoff, "maximum retry backoff")
+	flag.Float64Var(&p.BackoffMultiplier, "retry-multiplier", p.BackoffMultiplier, "retry backoff multiplier")
+	flag.Var((*codeList)(&p.RetryableCodes), "retry-codes", "comma separated status codes to retry")
+	flag.DurationVar(&p.HedgingDelay, "hedge-delay", 0, "send a hedged request after this delay, in place of retries, for unary calls only (0 disables hedging)")
+
+	return &p
+}
+
+// codeList is a flag.Value for a comma separated list of status codes.
+type codeList []codes.Code
+
+func (l *codeList) String() string {
+	if l == nil {
+		return ""
+	}
+	names := make([]string, len(*l))
+	for i, c := range *l {
+		names[i] = c.String()
+	}
+	return strings.Join(names, ",")
+}
+
+func (l *codeList) Set(s string) error {
+	*l = nil
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		c, err := ParseCode(name)
+		if err != nil {
+			return err
+		}
+		*l = append(*l, c)
+	}
+	return nil
+}
+
+// ParseCode returns the status code named by s. Names are matched without
+// regard to case or underscores, so "Unavailable", "UNAVAILABLE", and
+// "resource_exhausted" are all accepted, as are numeric codes.
+func ParseCode(s string) (codes.Code, error) {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil && n <= uint64(codes.Unauthenticated) {
+		return codes.Code(n), nil
+	}
+
+	want := strings.ToLower(strings.ReplaceAll(s, "_", ""))
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.ToLower(c.String()) == want {
+			return c, nil
+		}
+	}
+
+	return codes.Unknown, fmt.Errorf("unknown status code %q", s)
+}
+
+// codeName returns the service config name of c, for instance
+// RESOURCE_EXHAUSTED for codes.ResourceExhausted.
+func codeName(c codes.Code) string {
+	var b strings.Builder
+	prev := ' '
+	for _, r := range c.String() {
+		if unicode.IsUpper(r) && unicode.IsLower(prev) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		prev = r
+	}
+	return b.String()
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+type jsonName struct {
+	Service string `json:"service"`
+}
+
+type jsonRetryPolicy struct {
+	MaxAttempts          int      `json:"maxAttempts"`
+	InitialBackoff       string   `json:"initialBackoff"`
+	MaxBackoff           string   `json:"maxBackoff"`
+	BackoffMultiplier    float64  `json:"backoffMultiplier"`
+	RetryableStatusCodes []string `json:"retryableStatusCodes"`
+}
+
+type jsonMethodConfig struct {
+	Name        []jsonName       `json:"name"`
+	RetryPolicy *jsonRetryPolicy `json:"retryPolicy,omitempty"`
+}
+
+type jsonServiceConfig struct {
+	MethodConfig []jsonMethodConfig `json:"methodConfig"`
+}
+
+// ServiceConfig returns a gRPC service config in JSON which applies the
+// retry policy to every method of the named services, for instance
+// "api.Primes". The retry policy is left out when retries are disabled or
+// hedging is enabled.
+func (p Policy) ServiceConfig(services ...string) string {
+	mc := jsonMethodConfig{}
+	for _, s := range services {
+		mc.Name = append(mc.Name, jsonName{Service: s})
+	}
+
+	if p.MaxAttempts > 1 && p.HedgingDelay <= 0 {
+		rp := &jsonRetryPolicy{
+			MaxAttempts:       p.MaxAttempts,
+			InitialBackoff:    seconds(p.InitialBackoff),
+			MaxBackoff:        seconds(p.MaxBackoff),
+			BackoffMultiplier: p.BackoffMultiplier,
+		}
+		for _, c := range p.RetryableCodes {
+			rp.RetryableStatusCodes = append(rp.RetryableStatusCodes, codeName(c))
+		}
+		mc.RetryPolicy = rp
+	}
+
+	bs, err := json.Marshal(jsonServiceConfig{MethodConfig: []jsonMethodConfig{mc}})
+	if err != nil {
+		// The config is built from plain strings and numbers, so this cannot happen.
+		panic(err)
+	}
+
+	return string(bs)
+}
+
+// Validate reports whether the policy can be used by gRPC.
+func (p Policy) Validate() error {
+	if p.MaxAttempts <= 1 {
+		return nil
+	}
+	if p.InitialBackoff <= 0 || p.MaxBackoff <= 0 {
+		return fmt.Errorf("retry backoff must be positive")
+	}
+	if p.BackoffMultiplier <= 0 {
+		return fmt.Errorf("retry backoff multiplier must be positive")
+	}
+	if len(p.RetryableCodes) == 0 {
+		return fmt.Errorf("at least one retryable status code is required")
+	}
+	return nil
+}
+
+// ValidateStream reports whether the policy can be used by a client making
+// streaming calls, which are never hedged. Hedging would leave them without
+// retries, so it is refused.
+func (p Policy) ValidateStream() error {
+	if p.HedgingDelay > 0 {
+		return fmt.Errorf("streaming calls cannot be hedged, and hedging disables their retries")
+	}
+	return p.Validate()
+}
+
+// DialOptions returns the dial options which apply the policy to the named
+// services.
+func (p Policy) DialOptions(services ...string) []grpc.DialOption {
+	opts := []grpc.DialOption{grpc.WithDefaultServiceConfig(p.ServiceConfig(services...))}
+	if p.HedgingDelay > 0 && p.MaxAttempts > 1 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(p.HedgingInterceptor()))
+	}
+
+	return opts
+}
+
+// HedgingInterceptor returns a unary client interceptor which sends up to
+// MaxAttempts copies of a call, starting a new one every HedgingDelay until a
+// response arrives. The first successful response is returned and the other
+// attempts are cancelled. An error with a status code which is not in
+// RetryableCodes is returned immediately, otherwise the last error is
+// returned once every attempt has failed.
+func (p Policy) HedgingInterceptor() grpc.UnaryClientInterceptor {
+	nonFatal := make(map[codes.Code]bool)
+	for _, c := range p.RetryableCodes {
+		nonFatal[c] = true
+	}
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		replyMsg, ok := reply.(proto.Message)
+		if !ok || p.MaxAttempts <= 1 || p.HedgingDelay <= 0 {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type result struct {
+			reply proto.Message
+			err   error
+		}
+		// The channel is buffered so that abandoned attempts never block.
+		results := make(chan result, p.MaxAttempts)
+		attempt := func() {
+			r := proto.Clone(replyMsg)
+			proto.Reset(r)
+			err := invoker(ctx, method, req, r, cc, opts...)
+			results <- result{r, err}
+		}
+
+		timer := time.NewTimer(p.HedgingDelay)
+		defer timer.Stop()
+
+		go attempt()
+		started, finished := 1, 0
+		var lastErr error
+		for {
+			select {
+			case res := <-results:
+				finished++
+				if res.err == nil {
+					proto.Reset(replyMsg)
+					proto.Merge(replyMsg, res.reply)
+					return nil
+				}
+				lastErr = res.err
+				if !nonFatal[status.Code(res.err)] {
+					return res.err
+				}
+				if finished == started {
+					if started == p.MaxAttempts {
+						return lastErr
+					}
+					// Every outstanding attempt has failed, so don't wait
+					// for the hedging delay before trying again.
+					if !timer.Stop() {
+						select {
+						case <-timer.C:
+						default:
+						}
+					}
+					go attempt()
+					started++
+					timer.Reset(p.HedgingDelay)
+				}
+			case <-timer.C:
+				if started < p.MaxAttempts {
+					go attempt()
+					started++
+					timer.Reset(p.HedgingDelay)
+				}
+			case <-ctx.Done():
+				if lastErr != nil {
+					return lastErr
+				}
+				return status.FromContextError(ctx.Err()).Err()
+			}
+		}
+	}
+}
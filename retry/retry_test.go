@@ -0,0 +1,237 @@
+package retry
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/devries/grpc-tutorial/api"
+)
+
+// flakyServer fails calls according to fail, which is given the number of
+// the call starting from 1.
+type flakyServer struct {
+	api.UnimplementedPrimesServer
+
+	mu    sync.Mutex
+	calls int
+	fail  func(ctx context.Context, call int) error
+}
+
+func (s *flakyServer) GetPrimes(ctx context.Context, in *api.PrimeCount) (*api.PrimeNumbers, error) {
+	s.mu.Lock()
+	s.calls++
+	call := s.calls
+	s.mu.Unlock()
+
+	if err := s.fail(ctx, call); err != nil {
+		return nil, err
+	}
+
+	return &api.PrimeNumbers{Contents: []int64{2, 3, 5}[:in.Number]}, nil
+}
+
+func (s *flakyServer) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func dial(t *testing.T, srv api.PrimesServer, p Policy) api.PrimesClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	api.RegisterPrimesServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	opts := []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+	opts = append(opts, p.DialOptions("api.Primes")...)
+
+	conn, err := grpc.Dial("bufnet", opts...)
+	if err != nil {
+		t.Fatalf("dial failed: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return api.NewPrimesClient(conn)
+}
+
+func testPolicy() Policy {
+	p := DefaultPolicy()
+	p.InitialBackoff = 10 * time.Millisecond
+	p.MaxBackoff = 20 * time.Millisecond
+	return p
+}
+
+func getPrimes(t *testing.T, c api.PrimesClient) (*api.PrimeNumbers, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return c.GetPrimes(ctx, &api.PrimeCount{Number: 3})
+}
+
+func TestRetryUnavailable(t *testing.T) {
+	srv := &flakyServer{fail: func(_ context.Context, call int) error {
+		if call < 3 {
+			return status.Error(codes.Unavailable, "try again")
+		}
+		return nil
+	}}
+	c := dial(t, srv, testPolicy())
+
+	r, err := getPrimes(t, c)
+	if err != nil {
+		t.Fatalf("GetPrimes failed: %s", err)
+	}
+	if len(r.Contents) != 3 {
+		t.Errorf("got %v, want 3 primes", r.Contents)
+	}
+	if got := srv.Calls(); got != 3 {
+		t.Errorf("server received %d calls, want 3", got)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	srv := &flakyServer{fail: func(_ context.Context, _ int) error {
+		return status.Error(codes.Unavailable, "down")
+	}}
+	p := testPolicy()
+	p.MaxAttempts = 3
+	c := dial(t, srv, p)
+
+	_, err := getPrimes(t, c)
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("got error %v, want Unavailable", err)
+	}
+	if got := srv.Calls(); got != 3 {
+		t.Errorf("server received %d calls, want 3", got)
+	}
+}
+
+func TestNoRetryForOtherCodes(t *testing.T) {
+	srv := &flakyServer{fail: func(_ context.Context, _ int) error {
+		return status.Error(codes.InvalidArgument, "bad request")
+	}}
+	c := dial(t, srv, testPolicy())
+
+	_, err := getPrimes(t, c)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("got error %v, want InvalidArgument", err)
+	}
+	if got := srv.Calls(); got != 1 {
+		t.Errorf("server received %d calls, want 1", got)
+	}
+}
+
+func TestHedging(t *testing.T) {
+	srv := &flakyServer{fail: func(ctx context.Context, call int) error {
+		if call == 1 {
+			// The first attempt hangs until it is cancelled.
+			<-ctx.Done()
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return nil
+	}}
+	p := testPolicy()
+	p.HedgingDelay = 50 * time.Millisecond
+	c := dial(t, srv, p)
+
+	start := time.Now()
+	r, err := getPrimes(t, c)
+	if err != nil {
+		t.Fatalf("GetPrimes failed: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("hedged call took %s", elapsed)
+	}
+	if len(r.Contents) != 3 || r.Contents[2] != 5 {
+		t.Errorf("got %v, want [2 3 5]", r.Contents)
+	}
+	if got := srv.Calls(); got != 2 {
+		t.Errorf("server received %d calls, want 2", got)
+	}
+}
+
+func TestHedgingFatalError(t *testing.T) {
+	srv := &flakyServer{fail: func(_ context.Context, _ int) error {
+		return status.Error(codes.PermissionDenied, "no")
+	}}
+	p := testPolicy()
+	p.HedgingDelay = 50 * time.Millisecond
+	c := dial(t, srv, p)
+
+	_, err := getPrimes(t, c)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("got error %v, want PermissionDenied", err)
+	}
+	if got := srv.Calls(); got != 1 {
+		t.Errorf("server received %d calls, want 1", got)
+	}
+}
+
+func TestParseCode(t *testing.T) {
+	tests := map[string]codes.Code{
+		"Unavailable":        codes.Unavailable,
+		"UNAVAILABLE":        codes.Unavailable,
+		"resource_exhausted": codes.ResourceExhausted,
+		"DeadlineExceeded":   codes.DeadlineExceeded,
+		"14":                 codes.Unavailable,
+	}
+	for in, want := range tests {
+		got, err := ParseCode(in)
+		if err != nil || got != want {
+			t.Errorf("ParseCode(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+
+	if _, err := ParseCode("Sometimes"); err == nil {
+		t.Errorf("ParseCode accepted an unknown code")
+	}
+}
+
+func TestCodeName(t *testing.T) {
+	tests := map[codes.Code]string{
+		codes.OK:                "OK",
+		codes.Unavailable:       "UNAVAILABLE",
+		codes.ResourceExhausted: "RESOURCE_EXHAUSTED",
+		codes.DeadlineExceeded:  "DEADLINE_EXCEEDED",
+	}
+	for c, want := range tests {
+		if got := codeName(c); got != want {
+			t.Errorf("codeName(%v) = %q, want %q", c, got, want)
+		}
+	}
+}
+
+// Streaming clients refuse hedging, which would leave them without retries.
+func TestValidateStream(t *testing.T) {
+	p := DefaultPolicy()
+	if err := p.ValidateStream(); err != nil {
+		t.Errorf("default policy refused: %s", err)
+	}
+
+	p.HedgingDelay = 200 * time.Millisecond
+	if err := p.Validate(); err != nil {
+		t.Errorf("hedging refused for unary calls: %s", err)
+	}
+	if err := p.ValidateStream(); err == nil {
+		t.Error("hedging accepted for streaming calls")
+	}
+}
@@ -0,0 +1,196 @@
+// Package revocation rejects client certificates which their certificate
+// authority has revoked. The revoked certificates are read from a
+// certificate revocation list (CRL) file, which must be signed by one of the
+// authorities trusted for client certificates, and the file is read again
+// whenever it changes, so that a certificate can be revoked without
+// restarting the server.
+//
+// A client whose certificate is revoked fails the TLS handshake. Each
+// rejection is logged and counted in the primes_revoked_certificates_total
+// metric.
+package revocation
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/devries/grpc-tutorial/certs"
+)
+
+// DefaultReloadInterval is how often the CRL file is checked for changes if
+// CRL_RELOAD_INTERVAL is unset.
+const DefaultReloadInterval = 30 * time.Second
+
+var (
+	rejected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "primes_revoked_certificates_total",
+		Help: "Number of TLS handshakes rejected because the client certificate was revoked.",
+	})
+
+	loads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "primes_crl_loads_total",
+		Help: "Number of times a changed CRL file was read, by result.",
+	}, []string{"result"})
+)
+
+// Checker holds the revocation list read from a CRL file.
+type Checker struct {
+	path    string
+	issuers []*x509.Certificate
+
+	mu      sync.RWMutex
+	raw     []byte
+	list    *x509.RevocationList
+	revoked map[string]time.Time
+}
+
+// Open returns a checker for the CRL file at path, which must be signed by
+// one of issuers.
+func Open(path string, issuers []*x509.Certificate) (*Checker, error) {
+	c := &Checker{path: path, issuers: issuers}
+	if _, err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// FromEnv returns a checker for the CRL file in the CRL_FILE environment
+// variable, which is checked for changes every CRL_RELOAD_INTERVAL (a
+// duration such as "30s"). It returns nil if CRL_FILE is unset.
+func FromEnv(issuers []*x509.Certificate) (*Checker, error) {
+	path := os.Getenv("CRL_FILE")
+	if path == "" {
+		return nil, nil
+	}
+
+	interval := DefaultReloadInterval
+	if v := os.Getenv("CRL_RELOAD_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("CRL_RELOAD_INTERVAL must be a positive duration, not %q", v)
+		}
+		interval = d
+	}
+
+	c, err := Open(path, issuers)
+	if err != nil {
+		return nil, err
+	}
+	go c.watch(interval)
+
+	return c, nil
+}
+
+func (c *Checker) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		if _, err := c.Reload(); err != nil {
+			slog.Error("Could not reload certificate revocation list, keeping the previous one", "file", c.path, "error", err)
+		}
+	}
+}
+
+// Reload reads the CRL file, and replaces the revocation list if the file
+// has changed. It reports whether it did. If the file cannot be read or is
+// not a valid list the previous list stays in force.
+func (c *Checker) Reload() (bool, error) {
+	raw, err := os.ReadFile(c.path)
+	if err != nil {
+		loads.WithLabelValues("error").Inc()
+		return false, fmt.Errorf("could not read CRL: %w", err)
+	}
+
+	c.mu.RLock()
+	unchanged := bytes.Equal(raw, c.raw)
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	list, err := c.parse(raw)
+	if err != nil {
+		loads.WithLabelValues("error").Inc()
+		return false, err
+	}
+
+	revoked := make(map[string]time.Time, len(list.RevokedCertificateEntries))
+	for _, entry := range list.RevokedCertificateEntries {
+		revoked[entry.SerialNumber.String()] = entry.RevocationTime
+	}
+
+	c.mu.Lock()
+	c.raw, c.list, c.revoked = raw, list, revoked
+	c.mu.Unlock()
+
+	loads.WithLabelValues("success").Inc()
+	slog.Info("Loaded certificate revocation list", "file", c.path, "issuer", list.Issuer.String(), "revoked", len(revoked), "next_update", list.NextUpdate)
+	if !list.NextUpdate.IsZero() && time.Now().After(list.NextUpdate) {
+		slog.Warn("Certificate revocation list is out of date", "file", c.path, "next_update", list.NextUpdate)
+	}
+
+	return true, nil
+}
+
+// parse parses a revocation list and checks that it was signed by one of
+// the issuers.
+func (c *Checker) parse(raw []byte) (*x509.RevocationList, error) {
+	list, err := certs.ParseRevocationList(raw)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse CRL %s: %w", c.path, err)
+	}
+
+	for _, issuer := range c.issuers {
+		if bytes.Equal(issuer.RawSubject, list.RawIssuer) && list.CheckSignatureFrom(issuer) == nil {
+			return list, nil
+		}
+	}
+	return nil, fmt.Errorf("CRL %s is not signed by a trusted certificate authority", c.path)
+}
+
+// revokedAt returns when cert was revoked, if it is on the list.
+func (c *Checker) revokedAt(cert *x509.Certificate) (time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !bytes.Equal(cert.RawIssuer, c.list.RawIssuer) {
+		return time.Time{}, false
+	}
+	at, ok := c.revoked[cert.SerialNumber.String()]
+	return at, ok
+}
+
+// VerifyConnection is a function for tls.Config.VerifyConnection which
+// rejects clients whose certificate, or any certificate verifying it apart
+// from the root, has been revoked. Unlike VerifyPeerCertificate it is also
+// called when a TLS session is resumed, so a client cannot keep using a
+// session started before its certificate was revoked.
+func (c *Checker) VerifyConnection(cs tls.ConnectionState) error {
+	for _, chain := range cs.VerifiedChains {
+		for _, cert := range chain[:len(chain)-1] {
+			if at, ok := c.revokedAt(cert); ok {
+				rejected.Inc()
+				slog.Warn("Rejected revoked client certificate", "subject", cert.Subject.String(), "serial", cert.SerialNumber.String(), "revoked_at", at)
+				return fmt.Errorf("certificate %q was revoked", cert.Subject.String())
+			}
+		}
+	}
+	return nil
+}
+
+// Configure makes config reject revoked client certificates. It does nothing
+// if c is nil, so the result of FromEnv can be used whether or not a CRL file
+// was given.
+func (c *Checker) Configure(config *tls.Config) {
+	if c == nil {
+		return
+	}
+	config.VerifyConnection = c.VerifyConnection
+}
@@ -0,0 +1,88 @@
+package revocation
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devries/grpc-tutorial/certs"
+	"github.com/devries/grpc-tutorial/servertest"
+)
+
+func isRevoked(c *Checker, ca *servertest.CA, cert tls.Certificate) bool {
+	state := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert.Leaf, ca.Certificate}}}
+	return c.VerifyConnection(state) != nil
+}
+
+func TestReload(t *testing.T) {
+	ca := servertest.NewCA(t)
+	first := ca.Issue(t, "127.0.0.1")
+	second := ca.Issue(t, "127.0.0.1")
+
+	path := filepath.Join(t.TempDir(), certs.CRLFile)
+	ca.WriteCRL(t, path, first)
+
+	c, err := Open(path, []*x509.Certificate{ca.Certificate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isRevoked(c, ca, first) || isRevoked(c, ca, second) {
+		t.Fatal("only the first certificate should be revoked")
+	}
+
+	if reloaded, err := c.Reload(); err != nil || reloaded {
+		t.Errorf("unchanged file reloaded: %t, %v", reloaded, err)
+	}
+
+	ca.WriteCRL(t, path, first, second)
+	if reloaded, err := c.Reload(); err != nil || !reloaded {
+		t.Fatalf("changed file not reloaded: %t, %v", reloaded, err)
+	}
+	if !isRevoked(c, ca, second) {
+		t.Error("second certificate not revoked after reload")
+	}
+
+	// A broken file leaves the previous list in force.
+	if err := os.WriteFile(path, []byte("not a CRL"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Reload(); err == nil {
+		t.Error("broken file accepted")
+	}
+	if !isRevoked(c, ca, first) || !isRevoked(c, ca, second) {
+		t.Error("previous list dropped")
+	}
+}
+
+func TestUntrustedCRL(t *testing.T) {
+	ca := servertest.NewCA(t)
+	other := servertest.NewCA(t)
+
+	path := filepath.Join(t.TempDir(), certs.CRLFile)
+	other.WriteCRL(t, path)
+
+	if _, err := Open(path, []*x509.Certificate{ca.Certificate}); err == nil {
+		t.Error("CRL signed by another authority accepted")
+	}
+}
+
+// A CRL from one authority says nothing about certificates from another,
+// even if their serial numbers match.
+func TestOtherIssuer(t *testing.T) {
+	ca := servertest.NewCA(t)
+	other := servertest.NewCA(t)
+	cert := other.Issue(t, "127.0.0.1")
+
+	path := filepath.Join(t.TempDir(), certs.CRLFile)
+	ca.WriteCRL(t, path, cert)
+
+	c, err := Open(path, []*x509.Certificate{ca.Certificate, other.Certificate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isRevoked(c, other, cert) {
+		t.Error("certificate from another authority revoked")
+	}
+}
@@ -0,0 +1,100 @@
+// Package richstatus builds status errors carrying the standard
+// google.rpc error details, so that clients can tell which field of a request
+// was wrong, which quota was exceeded, and how long to wait before retrying,
+// and formats those details for people to read.
+package richstatus
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// withDetails returns an error with the given code and message, carrying
+// details. If the details cannot be attached the plain error is returned.
+func withDetails(c codes.Code, msg string, details ...protoadapt.MessageV1) error {
+	st := status.New(c, msg)
+	if ds, err := st.WithDetails(details...); err == nil {
+		st = ds
+	}
+	return st.Err()
+}
+
+func retryInfo(delay time.Duration) *errdetails.RetryInfo {
+	return &errdetails.RetryInfo{RetryDelay: durationpb.New(delay)}
+}
+
+// Violation describes what is wrong with one field of a request.
+type Violation struct {
+	Field       string
+	Description string
+}
+
+// BadRequest returns an InvalidArgument error with msg, listing the fields
+// of the request which were wrong.
+func BadRequest(msg string, violations ...Violation) error {
+	br := &errdetails.BadRequest{}
+	for _, v := range violations {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+	return withDetails(codes.InvalidArgument, msg, br)
+}
+
+// QuotaExceeded returns a ResourceExhausted error reporting that subject has
+// used up a quota, and that it may try again after delay.
+func QuotaExceeded(subject, description string, delay time.Duration) error {
+	return withDetails(codes.ResourceExhausted, description,
+		&errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{
+				{Subject: subject, Description: description},
+			},
+		},
+		retryInfo(delay),
+	)
+}
+
+// RetryAfter returns an error with the given code and message telling the
+// client to try again after delay.
+func RetryAfter(c codes.Code, msg string, delay time.Duration) error {
+	return withDetails(c, msg, retryInfo(delay))
+}
+
+// Format returns the text of err followed by any details it carries, one
+// per line.
+func Format(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err.Error()
+	}
+
+	var b strings.Builder
+	b.WriteString(err.Error())
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				fmt.Fprintf(&b, "\n  invalid field %s: %s", v.GetField(), v.GetDescription())
+			}
+		case *errdetails.QuotaFailure:
+			for _, v := range d.GetViolations() {
+				fmt.Fprintf(&b, "\n  quota exceeded for %s: %s", v.GetSubject(), v.GetDescription())
+			}
+		case *errdetails.RetryInfo:
+			fmt.Fprintf(&b, "\n  retry after %s", d.GetRetryDelay().AsDuration())
+		case error:
+			fmt.Fprintf(&b, "\n  undecodable detail: %s", d)
+		default:
+			fmt.Fprintf(&b, "\n  %T: %v", d, d)
+		}
+	}
+	return b.String()
+}
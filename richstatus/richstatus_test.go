@@ -0,0 +1,87 @@
+package richstatus
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHelpers(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    codes.Code
+		details []string
+		format  string
+	}{
+		{
+			name:    "bad request",
+			err:     BadRequest("invalid request", Violation{"number", "must not be negative"}),
+			code:    codes.InvalidArgument,
+			details: []string{"*errdetails.BadRequest"},
+			format:  "rpc error: code = InvalidArgument desc = invalid request\n  invalid field number: must not be negative",
+		},
+		{
+			name:    "quota exceeded",
+			err:     QuotaExceeded("client:alice", "daily quota exceeded", time.Hour),
+			code:    codes.ResourceExhausted,
+			details: []string{"*errdetails.QuotaFailure", "*errdetails.RetryInfo"},
+			format:  "rpc error: code = ResourceExhausted desc = daily quota exceeded\n  quota exceeded for client:alice: daily quota exceeded\n  retry after 1h0m0s",
+		},
+		{
+			name:    "retry after",
+			err:     RetryAfter(codes.Unavailable, "too busy", 2*time.Second),
+			code:    codes.Unavailable,
+			details: []string{"*errdetails.RetryInfo"},
+			format:  "rpc error: code = Unavailable desc = too busy\n  retry after 2s",
+		},
+		{
+			name:   "plain error",
+			err:    errors.New("boom"),
+			code:   codes.Unknown,
+			format: "boom",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			st := status.Convert(tc.err)
+			if st.Code() != tc.code {
+				t.Errorf("got code %s, want %s", st.Code(), tc.code)
+			}
+
+			details := st.Details()
+			if len(details) != len(tc.details) {
+				t.Fatalf("got details %v, want %v", details, tc.details)
+			}
+			for i, d := range details {
+				if got := fmt.Sprintf("%T", d); got != tc.details[i] {
+					t.Errorf("got detail %s, want %s", got, tc.details[i])
+				}
+			}
+
+			if got := Format(tc.err); got != tc.format {
+				t.Errorf("got %q, want %q", got, tc.format)
+			}
+		})
+	}
+}
+
+// The delay before retrying survives the trip through the status.
+func TestRetryDelay(t *testing.T) {
+	err := RetryAfter(codes.ResourceExhausted, "slow down", 1500*time.Millisecond)
+	for _, d := range status.Convert(err).Details() {
+		if ri, ok := d.(*errdetails.RetryInfo); ok {
+			if got := ri.GetRetryDelay().AsDuration(); got != 1500*time.Millisecond {
+				t.Errorf("got delay %s, want 1.5s", got)
+			}
+			return
+		}
+	}
+	t.Error("no RetryInfo detail")
+}
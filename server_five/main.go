@@ -4,16 +4,32 @@ import (
 	"context"
 	"io/ioutil"
 	"log"
-	"math"
+	"log/slog"
 	"net"
 	"os"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
 
+	"github.com/devries/grpc-tutorial/admission"
 	"github.com/devries/grpc-tutorial/api"
+	"github.com/devries/grpc-tutorial/apistream"
+	"github.com/devries/grpc-tutorial/discovery"
+	"github.com/devries/grpc-tutorial/forwarded"
+	"github.com/devries/grpc-tutorial/gateway"
+	"github.com/devries/grpc-tutorial/generator"
+	"github.com/devries/grpc-tutorial/legacy"
+	"github.com/devries/grpc-tutorial/logging"
+	"github.com/devries/grpc-tutorial/metrics"
+	primesv1 "github.com/devries/grpc-tutorial/primes/v1"
+	"github.com/devries/grpc-tutorial/ratelimit"
+	"github.com/devries/grpc-tutorial/shutdown"
+	"github.com/devries/grpc-tutorial/tracing"
+	"github.com/devries/grpc-tutorial/validate"
+	"github.com/devries/grpc-tutorial/web"
 
 	"crypto/tls"
 	"crypto/x509"
@@ -23,6 +39,10 @@ import (
 )
 
 func main() {
+	if err := logging.Setup(); err != nil {
+		log.Fatal(err)
+	}
+
 	port := os.Getenv("PORT")
 
 	if port == "" {
@@ -51,17 +71,72 @@ func main() {
 	}
 	log.Printf("Listening on port %s", port)
 
-	tlsConfig := &tls.Config{
-		ClientAuth:   tls.VerifyClientCertIfGiven,
-		Certificates: []tls.Certificate{certificate},
-		ClientCAs:    certPool,
+	metricsServer := metrics.ServeFromEnv()
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "server_five")
+	if err != nil {
+		log.Fatalf("could not set up tracing: %s", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	tlsConfig := newTLSConfig(certificate, certPool)
+
+	drainTimeout, err := shutdown.DrainTimeoutFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	admit, err := admission.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	limiter, err := ratelimit.FromEnv(validAuthorization)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	reflectionMode, err := discovery.ModeFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	reflectionOpts, err := discovery.ServerOptions(reflectionMode, reflectionAuthorized)
+	if err != nil {
+		log.Fatalf("could not configure reflection: %s", err)
+	}
+
+	// Calls forwarded by the HTTP servers below are made on behalf of the
+	// HTTP client, whose identity replaces theirs.
+	forwardedOpts := forwarded.ServerOptions(&certificate)
+
+	s, healthServer := newServer(credentials.NewTLS(tlsConfig), forwardedOpts, admit, limiter, reflectionOpts)
+
+	// Reflection lets tools such as grpcurl discover the services.
+	discovery.Register(s, reflectionMode)
+
+	// The REST gateway, and the gRPC-Web and Connect handlers for browsers,
+	// forward HTTP requests to the gRPC port, identifying themselves with the
+	// server certificate and passing on the address and any certificate of
+	// the HTTP client. They are served over HTTPS with the same certificates
+	// as the gRPC server.
+	loopback := credentials.NewTLS(&tls.Config{RootCAs: certPool, ServerName: "localhost", Certificates: []tls.Certificate{certificate}})
+	gatewayServer, err := gateway.ServeFromEnv("localhost:"+port, loopback, tlsConfig, primesv1.RegisterPrimesServiceHandler)
+	if err != nil {
+		log.Fatalf("could not start REST gateway: %s", err)
+	}
+	webServer, err := web.ServeFromEnv("localhost:"+port, loopback, tlsConfig, web.PrimesService, web.Primes, web.PrimeStream)
+	if err != nil {
+		log.Fatalf("could not start gRPC-Web and Connect: %s", err)
 	}
 
-	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)), grpc.UnaryInterceptor(AuthenticationInterceptor))
-	api.RegisterPrimesServer(s, &server{})
+	// On an interrupt or termination signal report that the server is no
+	// longer serving, then let in-flight calls finish before stopping.
+	stopped := shutdown.OnSignal(s, healthServer, drainTimeout, gatewayServer, webServer, metricsServer)
+
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("Failed to serve: %s", err)
 	}
+	<-stopped
 }
 
 type contextKey int
@@ -80,102 +155,128 @@ func Authorized(ctx context.Context) bool {
 
 // This is a UnaryServerInterceptor type which is a function with the signature below.
 func AuthenticationInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-	// We can perform a simple logging function here, getting the client address.
-	p, ok := peer.FromContext(ctx)
-	if ok {
-		log.Printf("Received Request from %s", p.Addr)
-	} else {
-		log.Printf("Received Request")
-	}
-
 	// We will get the token from the context
 	md, ok := metadata.FromIncomingContext(ctx)
 	authorized := false
 	if !ok {
-		log.Printf("Received no metadata")
+		slog.InfoContext(ctx, "Received no metadata")
 		authorized = false
 	} else {
-		tokens := md.Get("authorization")
-		if len(tokens) < 1 || tokens[0] != "Bearer HelloWorld" {
-			log.Printf("Invalid or Missing token")
+		if !validToken(md) {
+			slog.InfoContext(ctx, "Invalid or Missing token")
 			authorized = false
 		} else {
-			log.Printf("Received valid authorization token")
+			slog.InfoContext(ctx, "Received valid authorization token")
 			authorized = true
 		}
 	}
 
+	tracing.SetAuthorized(ctx, authorized)
+	logging.SetAuthorized(ctx, authorized)
 	ctx = context.WithValue(ctx, contextKeyAuthorized, authorized)
 
 	h, err := handler(ctx, req)
 	return h, err
 }
 
-type server struct{}
+// validToken checks the bearer token in the request metadata.
+func validToken(md metadata.MD) bool {
+	tokens := md.Get("authorization")
+	return len(tokens) > 0 && validAuthorization(tokens[0])
+}
 
-func (s *server) GetPrimes(ctx context.Context, in *api.PrimeCount) (*api.PrimeNumbers, error) {
-	// The interceptor places a boolean in the context to let us know if the client is authorized.
-	authorized := Authorized(ctx)
+// validAuthorization checks the value of an authorization header. The rate
+// limiter uses it to key only on tokens which the server accepts.
+func validAuthorization(v string) bool {
+	return v == "Bearer HelloWorld"
+}
 
-	// Next we check to see if it is false.
-	if !authorized {
-		log.Printf("Unauthorized client")
-		return nil, status.Errorf(codes.Unauthenticated, "Invalid or missing authorization token")
+// reflectionAuthorized allows clients with a valid token or client certificate
+// to use the reflection service.
+func reflectionAuthorized(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok && validToken(md) {
+		return true
 	}
-	log.Printf("Authorized client")
 
-	// Finally we handle the logic of the server
-	if in.Number < 0 {
-		retErr := status.Errorf(codes.InvalidArgument, "Requested number of primes must be positive")
-		log.Printf("Error: Asked for a negative amount")
-		return nil, retErr
-	}
+	return discovery.HasClientCertificate(ctx)
+}
 
-	if in.Number > 500 {
-		retErr := status.Errorf(codes.InvalidArgument, "%d is too many primes to return", in.Number)
-		log.Printf("Error: Asked for too many primes")
-		return nil, retErr
-	}
+// newServer returns the gRPC server with its interceptors and services
+// registered, along with the health service, which reports on them until the
+// server shuts down.
+func newServer(creds credentials.TransportCredentials, forwardedOpts []grpc.ServerOption, admit *admission.Controller, limiter *ratelimit.Limiter, reflectionOpts []grpc.ServerOption) (*grpc.Server, *health.Server) {
+	serverOpts := []grpc.ServerOption{grpc.Creds(creds)}
+	serverOpts = append(serverOpts, forwardedOpts...)
+	serverOpts = append(serverOpts, tracing.ServerOptions()...)
+	serverOpts = append(serverOpts, metrics.ServerOptions()...)
+	serverOpts = append(serverOpts, logging.ServerOptions()...)
+	serverOpts = append(serverOpts, limiter.ServerOptions()...)
+	serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(AuthenticationInterceptor))
+	serverOpts = append(serverOpts, validate.ServerOptions()...)
+	serverOpts = append(serverOpts, reflectionOpts...)
+
+	s := grpc.NewServer(serverOpts...)
+	srv := &server{Service: generator.NewService(admit)}
+	primesv1.RegisterPrimesServiceServer(s, srv)
+	// Clients of the unversioned APIs are served by the same handlers.
+	api.RegisterPrimesServer(s, legacy.Primes(srv))
+	apistream.RegisterPrimeStreamServer(s, legacy.PrimeStream(srv))
+
+	// The health service lets load balancers and probes check on the server.
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("primes.v1.PrimesService", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("api.Primes", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("apistream.PrimeStream", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+
+	return s, healthServer
+}
 
-	contentBox := make([]int64, in.Number)
+// newTLSConfig returns the TLS configuration of the server, which presents
+// certificate and checks any certificate a client presents against certPool.
+func newTLSConfig(certificate tls.Certificate, certPool *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		Certificates: []tls.Certificate{certificate},
+		ClientCAs:    certPool,
+	}
+}
 
-	// Prepare prime generator
-	ch := make(chan int64)
-	ctx, cancel := context.WithCancel(ctx)
+// server requires a valid token before serving the primes.
+type server struct {
+	*generator.Service
+}
 
-	go PrimeGenerator(ctx, ch)
+func (s *server) GetPrimes(ctx context.Context, in *primesv1.PrimeCount) (*primesv1.PrimeNumbers, error) {
+	// The interceptor places a boolean in the context to let us know if the client is authorized.
+	authorized := Authorized(ctx)
 
-	for i := int64(0); i < in.Number; i++ {
-		contentBox[i] = <-ch
+	// Next we check to see if it is false.
+	if !authorized {
+		slog.InfoContext(ctx, "Unauthorized client")
+		return nil, status.Errorf(codes.Unauthenticated, "Invalid or missing authorization token")
 	}
-	cancel()
+	slog.InfoContext(ctx, "Authorized client")
 
-	return &api.PrimeNumbers{Contents: contentBox}, nil
+	// Finally we handle the logic of the server
+	return s.Service.GetPrimes(ctx, in)
 }
 
-func PrimeGenerator(ctx context.Context, ch chan<- int64) {
-	primes := make([]int64, 0)
-	ch <- int64(2)
-	for i := int64(3); ; i += 2 {
-		isprime := true
-		iSqrt := int64(math.Floor(math.Sqrt(float64(i))))
-		for _, p := range primes {
-			if i%p == 0 {
-				isprime = false
-				break
-			}
-			if p > iSqrt {
-				break
-			}
-		}
-		if isprime {
-			select {
-			case ch <- i:
-				primes = append(primes, i)
-			case <-ctx.Done():
-				close(ch)
-				return
-			}
-		}
+// StreamPrimes checks the token itself, since the authentication interceptor
+// only sees unary calls.
+func (s *server) StreamPrimes(in *primesv1.PrimeCount, stream primesv1.PrimesService_StreamPrimesServer) error {
+	ctx := stream.Context()
+	md, _ := metadata.FromIncomingContext(ctx)
+	authorized := validToken(md)
+	tracing.SetAuthorized(ctx, authorized)
+	logging.SetAuthorized(ctx, authorized)
+
+	if !authorized {
+		slog.InfoContext(ctx, "Unauthorized client")
+		return status.Errorf(codes.Unauthenticated, "Invalid or missing authorization token")
 	}
+	slog.InfoContext(ctx, "Authorized client")
+
+	return s.Service.StreamPrimes(in, stream)
 }
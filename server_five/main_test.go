@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+
+	primesv1 "github.com/devries/grpc-tutorial/primes/v1"
+	"github.com/devries/grpc-tutorial/servertest"
+)
+
+// token sends a bearer token with each call.
+type token string
+
+func (tok token) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(tok)}, nil
+}
+
+func (tok token) RequireTransportSecurity() bool {
+	return true
+}
+
+func dial(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+
+	ca := servertest.NewCA(t)
+	tlsConfig := newTLSConfig(ca.Issue(t, "localhost"), ca.Pool)
+	s, _ := newServer(credentials.NewTLS(tlsConfig), nil, nil, nil, nil)
+	return servertest.Serve(t, s, ca.ClientCredentials())
+}
+
+// Every server serves both methods of the service.
+func TestPrimesService(t *testing.T) {
+	conn := dial(t)
+	servertest.CheckGetPrimes(t, conn, grpc.PerRPCCredentials(token("HelloWorld")))
+	servertest.CheckStreamPrimes(t, conn, grpc.PerRPCCredentials(token("HelloWorld")))
+}
+
+func TestUnauthenticated(t *testing.T) {
+	client := primesv1.NewPrimesServiceClient(dial(t))
+
+	tests := []struct {
+		name string
+		opts []grpc.CallOption
+	}{
+		{"no token", nil},
+		{"wrong token", []grpc.CallOption{grpc.PerRPCCredentials(token("GoodbyeWorld"))}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := client.GetPrimes(servertest.Context(t), &primesv1.PrimeCount{Number: 5}, tc.opts...)
+			servertest.ExpectCode(t, err, codes.Unauthenticated)
+
+			stream, err := client.StreamPrimes(servertest.Context(t), &primesv1.PrimeCount{Number: 5}, tc.opts...)
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			servertest.ExpectCode(t, err, codes.Unauthenticated)
+		})
+	}
+}
@@ -4,16 +4,31 @@ import (
 	"context"
 	"io/ioutil"
 	"log"
-	"math"
 	"net"
 	"os"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/peer"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
+	"github.com/devries/grpc-tutorial/admission"
 	"github.com/devries/grpc-tutorial/api"
+	"github.com/devries/grpc-tutorial/apistream"
+	"github.com/devries/grpc-tutorial/certs"
+	"github.com/devries/grpc-tutorial/discovery"
+	"github.com/devries/grpc-tutorial/forwarded"
+	"github.com/devries/grpc-tutorial/gateway"
+	"github.com/devries/grpc-tutorial/generator"
+	"github.com/devries/grpc-tutorial/legacy"
+	"github.com/devries/grpc-tutorial/logging"
+	"github.com/devries/grpc-tutorial/metrics"
+	primesv1 "github.com/devries/grpc-tutorial/primes/v1"
+	"github.com/devries/grpc-tutorial/ratelimit"
+	"github.com/devries/grpc-tutorial/revocation"
+	"github.com/devries/grpc-tutorial/shutdown"
+	"github.com/devries/grpc-tutorial/tracing"
+	"github.com/devries/grpc-tutorial/validate"
+	"github.com/devries/grpc-tutorial/web"
 
 	"crypto/tls"
 	"crypto/x509"
@@ -21,6 +36,10 @@ import (
 )
 
 func main() {
+	if err := logging.Setup(); err != nil {
+		log.Fatal(err)
+	}
+
 	port := os.Getenv("PORT")
 
 	if port == "" {
@@ -43,91 +62,127 @@ func main() {
 		log.Fatal("failed to append ca certificate to certificate pool")
 	}
 
+	issuers, err := certs.ParseCertificates(bs)
+	if err != nil {
+		log.Fatalf("failed to parse ca certificate: %s", err)
+	}
+	crl, err := revocation.FromEnv(issuers)
+	if err != nil {
+		log.Fatalf("could not load certificate revocation list: %s", err)
+	}
+
 	lis, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 	log.Printf("Listening on port %s", port)
 
-	tlsConfig := &tls.Config{
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		Certificates: []tls.Certificate{certificate},
-		ClientCAs:    certPool,
-	}
+	metricsServer := metrics.ServeFromEnv()
 
-	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
-	api.RegisterPrimesServer(s, &server{})
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("Failed to serve: %s", err)
+	shutdownTracing, err := tracing.Setup(context.Background(), "server_four")
+	if err != nil {
+		log.Fatalf("could not set up tracing: %s", err)
 	}
-}
+	defer shutdownTracing(context.Background())
 
-type server struct{}
+	tlsConfig := newTLSConfig(certificate, certPool, crl)
 
-func (s *server) GetPrimes(ctx context.Context, in *api.PrimeCount) (*api.PrimeNumbers, error) {
-	p, ok := peer.FromContext(ctx)
-	if ok {
-		log.Printf("Received Request from %s", p.Addr)
-		tlsAuth, ok := p.AuthInfo.(credentials.TLSInfo)
-		if ok {
-			cert := tlsAuth.State.PeerCertificates[0]
-			log.Printf("Client Certificate name: %s", cert.Subject)
-		}
-	} else {
-		log.Printf("Received Request")
+	drainTimeout, err := shutdown.DrainTimeoutFromEnv()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	if in.Number < 0 {
-		retErr := status.Errorf(codes.InvalidArgument, "Requested number of primes must be positive")
-		log.Printf("Error: Asked for a negative amount")
-		return nil, retErr
+	admit, err := admission.FromEnv()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	if in.Number > 500 {
-		retErr := status.Errorf(codes.InvalidArgument, "%d is too many primes to return", in.Number)
-		log.Printf("Error: Asked for too many primes")
-		return nil, retErr
+	limiter, err := ratelimit.FromEnv(nil)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	contentBox := make([]int64, in.Number)
+	reflectionMode, err := discovery.ModeFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	reflectionOpts, err := discovery.ServerOptions(reflectionMode, discovery.HasClientCertificate)
+	if err != nil {
+		log.Fatalf("could not configure reflection: %s", err)
+	}
+
+	// Calls forwarded by the HTTP servers below are made on behalf of the
+	// HTTP client, whose identity replaces theirs.
+	forwardedOpts := forwarded.ServerOptions(&certificate)
 
-	// Prepare prime generator
-	ch := make(chan int64)
-	ctx, cancel := context.WithCancel(ctx)
+	s, healthServer := newServer(credentials.NewTLS(tlsConfig), forwardedOpts, admit, limiter, reflectionOpts)
 
-	go PrimeGenerator(ctx, ch)
+	// Reflection lets tools such as grpcurl discover the services.
+	discovery.Register(s, reflectionMode)
 
-	for i := int64(0); i < in.Number; i++ {
-		contentBox[i] = <-ch
+	// The REST gateway, and the gRPC-Web and Connect handlers for browsers,
+	// forward HTTP requests to the gRPC port, identifying themselves with the
+	// server certificate and passing on the certificate of the HTTP client.
+	// They ask HTTP clients for certificates just as the gRPC server does.
+	loopback := credentials.NewTLS(&tls.Config{RootCAs: certPool, ServerName: "localhost", Certificates: []tls.Certificate{certificate}})
+	gatewayServer, err := gateway.ServeFromEnv("localhost:"+port, loopback, tlsConfig, primesv1.RegisterPrimesServiceHandler)
+	if err != nil {
+		log.Fatalf("could not start REST gateway: %s", err)
+	}
+	webServer, err := web.ServeFromEnv("localhost:"+port, loopback, tlsConfig, web.PrimesService, web.Primes, web.PrimeStream)
+	if err != nil {
+		log.Fatalf("could not start gRPC-Web and Connect: %s", err)
 	}
-	cancel()
 
-	return &api.PrimeNumbers{Contents: contentBox}, nil
+	// On an interrupt or termination signal report that the server is no
+	// longer serving, then let in-flight calls finish before stopping.
+	stopped := shutdown.OnSignal(s, healthServer, drainTimeout, gatewayServer, webServer, metricsServer)
+
+	if err := s.Serve(lis); err != nil {
+		log.Fatalf("Failed to serve: %s", err)
+	}
+	<-stopped
+}
+
+// newServer returns the gRPC server with its interceptors and services
+// registered, along with the health service, which reports on them until the
+// server shuts down.
+func newServer(creds credentials.TransportCredentials, forwardedOpts []grpc.ServerOption, admit *admission.Controller, limiter *ratelimit.Limiter, reflectionOpts []grpc.ServerOption) (*grpc.Server, *health.Server) {
+	serverOpts := []grpc.ServerOption{grpc.Creds(creds)}
+	serverOpts = append(serverOpts, forwardedOpts...)
+	serverOpts = append(serverOpts, tracing.ServerOptions()...)
+	serverOpts = append(serverOpts, metrics.ServerOptions()...)
+	serverOpts = append(serverOpts, logging.ServerOptions()...)
+	serverOpts = append(serverOpts, limiter.ServerOptions()...)
+	serverOpts = append(serverOpts, validate.ServerOptions()...)
+	serverOpts = append(serverOpts, reflectionOpts...)
+
+	s := grpc.NewServer(serverOpts...)
+	srv := generator.NewService(admit)
+	primesv1.RegisterPrimesServiceServer(s, srv)
+	// Clients of the unversioned APIs are served by the same handlers.
+	api.RegisterPrimesServer(s, legacy.Primes(srv))
+	apistream.RegisterPrimeStreamServer(s, legacy.PrimeStream(srv))
+
+	// The health service lets load balancers and probes check on the server.
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("primes.v1.PrimesService", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("api.Primes", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("apistream.PrimeStream", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+
+	return s, healthServer
 }
 
-func PrimeGenerator(ctx context.Context, ch chan<- int64) {
-	primes := make([]int64, 0)
-	ch <- int64(2)
-	for i := int64(3); ; i += 2 {
-		isprime := true
-		iSqrt := int64(math.Floor(math.Sqrt(float64(i))))
-		for _, p := range primes {
-			if i%p == 0 {
-				isprime = false
-				break
-			}
-			if p > iSqrt {
-				break
-			}
-		}
-		if isprime {
-			select {
-			case ch <- i:
-				primes = append(primes, i)
-			case <-ctx.Done():
-				close(ch)
-				return
-			}
-		}
+// newTLSConfig returns the TLS configuration of the server, which presents
+// certificate and requires clients to present a certificate signed by a CA in
+// certPool and not revoked by crl, if it is not nil.
+func newTLSConfig(certificate tls.Certificate, certPool *x509.CertPool, crl *revocation.Checker) *tls.Config {
+	config := &tls.Config{
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		Certificates: []tls.Certificate{certificate},
+		ClientCAs:    certPool,
 	}
+	crl.Configure(config)
+	return config
 }
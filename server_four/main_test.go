@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+
+	primesv1 "github.com/devries/grpc-tutorial/primes/v1"
+	"github.com/devries/grpc-tutorial/revocation"
+	"github.com/devries/grpc-tutorial/servertest"
+)
+
+// Every server serves both methods of the service.
+func TestPrimesService(t *testing.T) {
+	ca := servertest.NewCA(t)
+	tlsConfig := newTLSConfig(ca.Issue(t, "localhost"), ca.Pool, nil)
+	s, _ := newServer(credentials.NewTLS(tlsConfig), nil, nil, nil, nil)
+	conn := servertest.Serve(t, s, ca.ClientCredentials(ca.Issue(t, "127.0.0.1")))
+
+	servertest.CheckGetPrimes(t, conn)
+	servertest.CheckStreamPrimes(t, conn)
+}
+
+// Clients must present a certificate from the server's CA.
+func TestClientCertificateRequired(t *testing.T) {
+	ca := servertest.NewCA(t)
+	other := servertest.NewCA(t)
+
+	tests := []struct {
+		name  string
+		creds credentials.TransportCredentials
+	}{
+		{"no certificate", ca.ClientCredentials()},
+		{"certificate from another CA", ca.ClientCredentials(other.Issue(t, "127.0.0.1"))},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tlsConfig := newTLSConfig(ca.Issue(t, "localhost"), ca.Pool, nil)
+			s, _ := newServer(credentials.NewTLS(tlsConfig), nil, nil, nil, nil)
+			conn := servertest.Serve(t, s, tc.creds)
+
+			_, err := primesv1.NewPrimesServiceClient(conn).GetPrimes(servertest.Context(t), &primesv1.PrimeCount{Number: 5})
+			servertest.ExpectCode(t, err, codes.Unavailable)
+		})
+	}
+}
+
+// Clients whose certificates are on the CRL are turned away, including those
+// revoked after the server started.
+func TestRevokedClientCertificate(t *testing.T) {
+	ca := servertest.NewCA(t)
+	client := ca.Issue(t, "127.0.0.1")
+	revoked := ca.Issue(t, "127.0.0.1")
+
+	crlFile := filepath.Join(t.TempDir(), "crl.pem")
+	ca.WriteCRL(t, crlFile, revoked)
+	crl, err := revocation.Open(crlFile, []*x509.Certificate{ca.Certificate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig := newTLSConfig(ca.Issue(t, "localhost"), ca.Pool, crl)
+
+	call := func(t *testing.T, cert tls.Certificate) error {
+		s, _ := newServer(credentials.NewTLS(tlsConfig), nil, nil, nil, nil)
+		conn := servertest.Serve(t, s, ca.ClientCredentials(cert))
+		_, err := primesv1.NewPrimesServiceClient(conn).GetPrimes(servertest.Context(t), &primesv1.PrimeCount{Number: 5})
+		return err
+	}
+
+	if err := call(t, client); err != nil {
+		t.Errorf("client rejected: %s", err)
+	}
+	servertest.ExpectCode(t, call(t, revoked), codes.Unavailable)
+
+	ca.WriteCRL(t, crlFile, revoked, client)
+	if reloaded, err := crl.Reload(); err != nil || !reloaded {
+		t.Fatalf("CRL not reloaded: %v", err)
+	}
+	servertest.ExpectCode(t, call(t, client), codes.Unavailable)
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/devries/grpc-tutorial/servertest"
+)
+
+// Every server serves both methods of the service.
+func TestPrimesService(t *testing.T) {
+	s, _ := newServer(nil, nil, nil, nil)
+	conn := servertest.Serve(t, s, insecure.NewCredentials())
+
+	servertest.CheckGetPrimes(t, conn)
+	servertest.CheckStreamPrimes(t, conn)
+}
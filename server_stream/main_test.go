@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/credentials"
+
+	"github.com/devries/grpc-tutorial/servertest"
+)
+
+// Every server serves both methods of the service.
+func TestPrimesService(t *testing.T) {
+	ca := servertest.NewCA(t)
+	tlsConfig := newTLSConfig(ca.Issue(t, "localhost"), ca.Pool)
+	s, _ := newServer(credentials.NewTLS(tlsConfig), nil, nil, nil, nil, nil)
+	conn := servertest.Serve(t, s, ca.ClientCredentials())
+
+	servertest.CheckGetPrimes(t, conn)
+	servertest.CheckStreamPrimes(t, conn)
+}
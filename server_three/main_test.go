@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+
+	primesv1 "github.com/devries/grpc-tutorial/primes/v1"
+	"github.com/devries/grpc-tutorial/servertest"
+)
+
+// Every server serves both methods of the service.
+func TestPrimesService(t *testing.T) {
+	ca := servertest.NewCA(t)
+	tlsConfig := newTLSConfig(ca.Issue(t, "localhost"), ca.Pool)
+	s, _ := newServer(credentials.NewTLS(tlsConfig), nil, nil, nil, nil)
+	conn := servertest.Serve(t, s, ca.ClientCredentials())
+
+	servertest.CheckGetPrimes(t, conn)
+	servertest.CheckStreamPrimes(t, conn)
+}
+
+// Clients must reject a server whose certificate comes from another CA.
+func TestUntrustedServer(t *testing.T) {
+	ca := servertest.NewCA(t)
+	tlsConfig := newTLSConfig(ca.Issue(t, "localhost"), ca.Pool)
+	s, _ := newServer(credentials.NewTLS(tlsConfig), nil, nil, nil, nil)
+	conn := servertest.Serve(t, s, servertest.NewCA(t).ClientCredentials())
+
+	_, err := primesv1.NewPrimesServiceClient(conn).GetPrimes(servertest.Context(t), &primesv1.PrimeCount{Number: 5})
+	servertest.ExpectCode(t, err, codes.Unavailable)
+}
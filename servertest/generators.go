@@ -0,0 +1,101 @@
+package servertest
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/devries/grpc-tutorial/deadline"
+	primesv1 "github.com/devries/grpc-tutorial/primes/v1"
+)
+
+// CheckGeneratorsStop checks that getPrimes, the GetPrimes method of a unary
+// server, leaves no prime generator running once it returns: when no primes
+// are asked for, and when partial results are cut off straight away.
+func CheckGeneratorsStop(t *testing.T, getPrimes func(context.Context, *primesv1.PrimeCount) (*primesv1.PrimeNumbers, error)) {
+	t.Run("none", func(t *testing.T) {
+		checkGoroutines(t, func() {
+			r, err := getPrimes(Context(t), &primesv1.PrimeCount{Number: 0})
+			if err != nil || len(r.GetContents()) != 0 {
+				t.Errorf("got %v, %v, want no primes", r, err)
+			}
+		})
+	})
+
+	t.Run("immediate cutoff", func(t *testing.T) {
+		checkGoroutines(t, func() {
+			// The cutoff comes Margin before the deadline, so at once.
+			ctx, cancel := context.WithTimeout(Context(t), deadline.Margin)
+			defer cancel()
+			if _, err := getPrimes(ctx, &primesv1.PrimeCount{Number: 500, AllowPartial: true}); err != nil {
+				t.Errorf("GetPrimes failed: %s", err)
+			}
+		})
+	})
+}
+
+// CheckStreamGeneratorsStop checks that streamPrimes, the StreamPrimes
+// method of a streaming server, leaves no prime generator running once it
+// returns: when no primes are asked for, and when the client goes away.
+func CheckStreamGeneratorsStop(t *testing.T, streamPrimes func(*primesv1.PrimeCount, primesv1.PrimesService_StreamPrimesServer) error) {
+	t.Run("none", func(t *testing.T) {
+		checkGoroutines(t, func() {
+			s := &closingStream{ctx: Context(t), limit: 1}
+			if err := streamPrimes(&primesv1.PrimeCount{Number: 0}, s); err != nil || s.sent != 0 {
+				t.Errorf("sent %d primes, %v, want none", s.sent, err)
+			}
+		})
+	})
+
+	t.Run("client gone", func(t *testing.T) {
+		checkGoroutines(t, func() {
+			s := &closingStream{ctx: Context(t), limit: 3}
+			if err := streamPrimes(&primesv1.PrimeCount{Number: 500}, s); err == nil {
+				t.Error("stream did not fail")
+			}
+		})
+	})
+}
+
+// closingStream is a server stream whose client goes away after limit
+// primes.
+type closingStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	limit int
+	sent  int
+}
+
+func (s *closingStream) Context() context.Context { return s.ctx }
+
+func (s *closingStream) Send(*primesv1.PrimeNumber) error {
+	if s.sent == s.limit {
+		return errors.New("client gone")
+	}
+	s.sent++
+	return nil
+}
+
+// checkGoroutines calls fn a number of times, and fails the test if it
+// leaves goroutines running, allowing a moment for them to finish.
+func checkGoroutines(t *testing.T, fn func()) {
+	t.Helper()
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		fn()
+	}
+
+	until := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(until) {
+			t.Errorf("%d goroutines left running", runtime.NumGoroutine()-before)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
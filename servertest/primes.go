@@ -0,0 +1,152 @@
+package servertest
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/devries/grpc-tutorial/api"
+	"github.com/devries/grpc-tutorial/apistream"
+	primesv1 "github.com/devries/grpc-tutorial/primes/v1"
+)
+
+var firstPrimes = []int64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29}
+
+// Context returns a context for one call, cancelled when the test ends.
+func Context(t testing.TB) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// ExpectCode fails the test unless err has the status code want.
+func ExpectCode(t testing.TB, err error, want codes.Code) {
+	t.Helper()
+
+	if got := status.Code(err); got != want {
+		t.Errorf("got %s (%v), want %s", got, err, want)
+	}
+}
+
+// CheckGetPrimes checks the GetPrimes method of a unary server over conn,
+// through both primes.v1 and the unversioned api.Primes: that it returns the
+// primes asked for, and fails with InvalidArgument for a negative number or
+// more than 500. opts are added to every call, for example to send a token.
+func CheckGetPrimes(t *testing.T, conn *grpc.ClientConn, opts ...grpc.CallOption) {
+	v1 := primesv1.NewPrimesServiceClient(conn)
+	old := api.NewPrimesClient(conn)
+
+	t.Run("success", func(t *testing.T) {
+		r, err := v1.GetPrimes(Context(t), &primesv1.PrimeCount{Number: 10}, opts...)
+		if err != nil {
+			t.Fatalf("GetPrimes failed: %s", err)
+		}
+		if !reflect.DeepEqual(r.Contents, firstPrimes) {
+			t.Errorf("got %v, want %v", r.Contents, firstPrimes)
+		}
+	})
+
+	t.Run("unversioned", func(t *testing.T) {
+		r, err := old.GetPrimes(Context(t), &api.PrimeCount{Number: 10}, opts...)
+		if err != nil {
+			t.Fatalf("GetPrimes failed: %s", err)
+		}
+		if !reflect.DeepEqual(r.Contents, firstPrimes) {
+			t.Errorf("got %v, want %v", r.Contents, firstPrimes)
+		}
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		_, err := v1.GetPrimes(Context(t), &primesv1.PrimeCount{Number: -1}, opts...)
+		ExpectCode(t, err, codes.InvalidArgument)
+		_, err = old.GetPrimes(Context(t), &api.PrimeCount{Number: -1}, opts...)
+		ExpectCode(t, err, codes.InvalidArgument)
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		_, err := v1.GetPrimes(Context(t), &primesv1.PrimeCount{Number: 501}, opts...)
+		ExpectCode(t, err, codes.InvalidArgument)
+		_, err = old.GetPrimes(Context(t), &api.PrimeCount{Number: 501}, opts...)
+		ExpectCode(t, err, codes.InvalidArgument)
+	})
+}
+
+// CheckStreamPrimes checks the StreamPrimes method of a streaming server
+// over conn, through both primes.v1 and the unversioned
+// apistream.PrimeStream: that it streams the primes asked for, and fails with
+// InvalidArgument for a negative number or more than ten million.
+func CheckStreamPrimes(t *testing.T, conn *grpc.ClientConn, opts ...grpc.CallOption) {
+	v1 := primesv1.NewPrimesServiceClient(conn)
+	old := apistream.NewPrimeStreamClient(conn)
+
+	stream := func(t *testing.T, n int64) ([]int64, error) {
+		s, err := v1.StreamPrimes(Context(t), &primesv1.PrimeCount{Number: n}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return receive(s.Recv)
+	}
+	streamOld := func(t *testing.T, n int64) ([]int64, error) {
+		s, err := old.GetPrimes(Context(t), &apistream.PrimeCount{Number: n}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return receive(s.Recv)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		got, err := stream(t, 10)
+		if err != nil {
+			t.Fatalf("StreamPrimes failed: %s", err)
+		}
+		if !reflect.DeepEqual(got, firstPrimes) {
+			t.Errorf("got %v, want %v", got, firstPrimes)
+		}
+	})
+
+	t.Run("unversioned", func(t *testing.T) {
+		got, err := streamOld(t, 10)
+		if err != nil {
+			t.Fatalf("GetPrimes failed: %s", err)
+		}
+		if !reflect.DeepEqual(got, firstPrimes) {
+			t.Errorf("got %v, want %v", got, firstPrimes)
+		}
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		_, err := stream(t, -1)
+		ExpectCode(t, err, codes.InvalidArgument)
+		_, err = streamOld(t, -1)
+		ExpectCode(t, err, codes.InvalidArgument)
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		_, err := stream(t, 10000001)
+		ExpectCode(t, err, codes.InvalidArgument)
+		_, err = streamOld(t, 10000001)
+		ExpectCode(t, err, codes.InvalidArgument)
+	})
+}
+
+// receive collects the values of the prime messages returned by recv until
+// the stream ends.
+func receive[M interface{ GetValue() int64 }](recv func() (M, error)) ([]int64, error) {
+	var values []int64
+	for {
+		m, err := recv()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return values, err
+		}
+		values = append(values, m.GetValue())
+	}
+}
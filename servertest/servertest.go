@@ -0,0 +1,112 @@
+// Package servertest runs the servers in-process for tests. A server is
+// served over an in-memory bufconn listener rather than a network port, and
+// the TLS variants are given certificates from a throwaway certificate
+// authority made for the test by the certs package. The same checks of the
+// primes services can then be run against every variant.
+package servertest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/devries/grpc-tutorial/certs"
+)
+
+// Serve serves s on an in-memory listener until the test ends, and returns a
+// connection to it made with creds.
+func Serve(t testing.TB, s *grpc.Server, creds credentials.TransportCredentials) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(creds),
+	)
+	if err != nil {
+		t.Fatalf("dial failed: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// CA is a throwaway certificate authority.
+type CA struct {
+	// Pool holds the certificate of the authority, for verifying the
+	// certificates it issues.
+	Pool *x509.CertPool
+	// Certificate is the certificate of the authority.
+	Certificate *x509.Certificate
+
+	authority *certs.Authority
+}
+
+// cas numbers the authorities, so that each has its own subject.
+var cas atomic.Int64
+
+// NewCA returns a new certificate authority.
+func NewCA(t testing.TB) *CA {
+	t.Helper()
+
+	authority, err := certs.NewAuthority(fmt.Sprintf("servertest root ca %d", cas.Add(1)), 24*time.Hour)
+	if err != nil {
+		t.Fatalf("could not create CA: %s", err)
+	}
+
+	return &CA{Pool: authority.Pool(), Certificate: authority.Certificate, authority: authority}
+}
+
+// Issue returns a certificate for names, which are host names or IP
+// addresses, usable by both servers and clients.
+func (ca *CA) Issue(t testing.TB, names ...string) tls.Certificate {
+	t.Helper()
+
+	cert, err := ca.authority.Issue("", names, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// WriteCRL writes a certificate revocation list signed by ca to path,
+// revoking revoked.
+func (ca *CA) WriteCRL(t testing.TB, path string, revoked ...tls.Certificate) {
+	t.Helper()
+
+	var entries []x509.RevocationListEntry
+	for _, cert := range revoked {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: cert.Leaf.SerialNumber, RevocationTime: time.Now()})
+	}
+	der, err := ca.authority.RevocationList(entries, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := certs.SaveRevocationList(path, der); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ClientCredentials returns credentials which trust the certificates issued
+// by ca for "localhost", and present certificates to the server.
+func (ca *CA) ClientCredentials(certificates ...tls.Certificate) credentials.TransportCredentials {
+	return credentials.NewTLS(&tls.Config{
+		RootCAs:      ca.Pool,
+		ServerName:   "localhost",
+		Certificates: certificates,
+	})
+}
@@ -0,0 +1,114 @@
+// Package shutdown stops the servers gracefully when they receive an
+// interrupt or termination signal, so that in-flight calls such as long
+// server streams are not cut off, whether they were made over gRPC or through
+// the HTTP servers alongside it.
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+)
+
+// DefaultDrainTimeout is how long in-flight calls are given to finish.
+const DefaultDrainTimeout = 30 * time.Second
+
+// DrainTimeoutFromEnv reads the drain timeout from the DRAIN_TIMEOUT
+// environment variable, which is a duration such as "10s".
+func DrainTimeoutFromEnv() (time.Duration, error) {
+	v := os.Getenv("DRAIN_TIMEOUT")
+	if v == "" {
+		return DefaultDrainTimeout, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("DRAIN_TIMEOUT must be a non-negative duration, not %q", v)
+	}
+
+	return d, nil
+}
+
+// OnSignal waits in the background for an interrupt or termination signal.
+// When one arrives the health service reports NOT_SERVING, the servers stop
+// accepting new connections and calls, and in-flight calls are given up to
+// drain to finish before the servers are stopped outright. The HTTP servers,
+// such as the REST gateway, are drained alongside the gRPC server, which
+// finishes the calls they have already forwarded to it while refusing new
+// ones; nil HTTP servers are skipped. A second signal stops the servers
+// immediately. The returned channel is closed once they have stopped.
+func OnSignal(s *grpc.Server, healthServer *health.Server, drain time.Duration, httpServers ...*http.Server) <-chan struct{} {
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		<-sig
+		log.Printf("Shutting down, waiting up to %s for in-flight calls", drain)
+		if healthServer != nil {
+			healthServer.Shutdown()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Every server stops accepting at once, so that a long stream through
+		// an HTTP server does not keep the gRPC port open.
+		var wg sync.WaitGroup
+		for _, srv := range httpServers {
+			if srv != nil {
+				wg.Add(1)
+				go func(srv *http.Server) {
+					defer wg.Done()
+					srv.Shutdown(ctx)
+				}(srv)
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.GracefulStop()
+		}()
+
+		drained := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(drained)
+		}()
+
+		timer := time.NewTimer(drain)
+		defer timer.Stop()
+
+		select {
+		case <-drained:
+			log.Printf("All calls finished")
+			return
+		case <-timer.C:
+			log.Printf("Drain timeout reached, stopping")
+		case <-sig:
+			log.Printf("Received second signal, stopping")
+		}
+
+		cancel()
+		for _, srv := range httpServers {
+			if srv != nil {
+				srv.Close()
+			}
+		}
+		s.Stop()
+		<-drained
+	}()
+
+	return stopped
+}
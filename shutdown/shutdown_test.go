@@ -0,0 +1,117 @@
+package shutdown
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	return lis
+}
+
+// check makes a health check on a new connection to addr.
+func check(addr string) error {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+// The gRPC port refuses new calls as soon as the signal arrives, even while
+// an HTTP stream is still draining.
+func TestGRPCStopsWhileHTTPDrains(t *testing.T) {
+	grpcLis := listen(t)
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+	go s.Serve(grpcLis)
+	t.Cleanup(s.Stop)
+
+	if err := check(grpcLis.Addr().String()); err != nil {
+		t.Fatalf("health check failed before shutdown: %s", err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	httpLis := listen(t)
+	httpServer := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+	go httpServer.Serve(httpLis)
+	t.Cleanup(func() { httpServer.Close() })
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + httpLis.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		bs, _ := io.ReadAll(resp.Body)
+		body <- string(bs)
+	}()
+	<-started
+
+	stopped := OnSignal(s, healthServer, 10*time.Second, httpServer)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := check(grpcLis.Addr().String())
+		if status.Code(err) == codes.Unavailable {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("gRPC port still serving during the HTTP drain: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-stopped:
+		t.Fatal("stopped before the HTTP stream finished")
+	default:
+	}
+
+	close(release)
+	if got := <-body; got != "done" {
+		t.Errorf("HTTP stream got %q, want it to finish", got)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not stopped after the HTTP stream finished")
+	}
+}
@@ -0,0 +1,203 @@
+// Package tracing records OpenTelemetry traces of the calls between the
+// clients and servers. Spans are exported with OTLP over gRPC to the
+// collector named by the standard OTEL_EXPORTER_OTLP_ENDPOINT (or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) environment variable, and tracing is
+// disabled when neither is set.
+package tracing
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/stats"
+
+	"github.com/devries/grpc-tutorial/internal/primesmsg"
+)
+
+// Attributes added to the span of each call.
+const (
+	RequestedKey  = attribute.Key("primes.requested")
+	ReturnedKey   = attribute.Key("primes.returned")
+	AuthorizedKey = attribute.Key("primes.authorized")
+	PeerKey       = attribute.Key("primes.peer.address")
+)
+
+// Enabled reports whether an OTLP endpoint has been configured.
+func Enabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs a global tracer provider which exports spans for the named
+// service. The returned function flushes any remaining spans and must be
+// called before the program exits. If no endpoint is configured Setup does
+// nothing.
+func Setup(ctx context.Context, service string) (func(context.Context) error, error) {
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// The exporter reads the endpoint, and whether to use TLS, from the
+	// standard OTEL_EXPORTER_OTLP_* environment variables.
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(service)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return tp.Shutdown, nil
+}
+
+// ServerOptions returns the server options which trace every call.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unaryServerInterceptor),
+		grpc.ChainStreamInterceptor(streamServerInterceptor),
+	}
+}
+
+// DialOptions returns the dial options which trace every call.
+func DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithStatsHandler(&clientHandler{Handler: otelgrpc.NewClientHandler()}),
+	}
+}
+
+// SetAuthorized records the outcome of authenticating the client on the
+// span of the current call.
+func SetAuthorized(ctx context.Context, authorized bool) {
+	trace.SpanFromContext(ctx).SetAttributes(AuthorizedKey.Bool(authorized))
+}
+
+func setRequested(span trace.Span, req interface{}) {
+	if n, ok := req.(primesmsg.Number); ok {
+		span.SetAttributes(RequestedKey.Int64(n.GetNumber()))
+	}
+}
+
+func setReturned(span trace.Span, resp interface{}) {
+	if c, ok := resp.(primesmsg.Contents); ok {
+		span.SetAttributes(ReturnedKey.Int(len(c.GetContents())))
+	}
+}
+
+func setPeer(ctx context.Context, span trace.Span) {
+	if p, ok := peer.FromContext(ctx); ok {
+		span.SetAttributes(PeerKey.String(p.Addr.String()))
+	}
+}
+
+func unaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	span := trace.SpanFromContext(ctx)
+	setPeer(ctx, span)
+	setRequested(span, req)
+
+	resp, err := handler(ctx, req)
+	if err == nil {
+		setReturned(span, resp)
+	}
+
+	return resp, err
+}
+
+func streamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := ss.Context()
+	span := trace.SpanFromContext(ctx)
+	setPeer(ctx, span)
+
+	ts := &tracedServerStream{ServerStream: ss, span: span}
+	err := handler(srv, ts)
+	span.SetAttributes(ReturnedKey.Int64(ts.sent.Load()))
+
+	return err
+}
+
+// tracedServerStream records the request and counts the primes sent.
+type tracedServerStream struct {
+	grpc.ServerStream
+	span trace.Span
+	sent atomic.Int64
+}
+
+func (s *tracedServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		setRequested(s.span, m)
+	}
+	return err
+}
+
+func (s *tracedServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if _, ok := m.(primesmsg.Value); ok && err == nil {
+		s.sent.Add(1)
+	}
+	return err
+}
+
+// clientHandler adds the primes requested and returned to the span which
+// otelgrpc starts for each call. The span is only created once the call is
+// under way, too late for an interceptor to annotate it, but the stats
+// handler sees the messages of the call with the span in their context.
+type clientHandler struct {
+	stats.Handler
+}
+
+// receivedKey is the context key of the number of primes received by a call.
+type receivedKey struct{}
+
+func (h *clientHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	ctx = h.Handler.TagRPC(ctx, info)
+	return context.WithValue(ctx, receivedKey{}, new(atomic.Int64))
+}
+
+func (h *clientHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	span := trace.SpanFromContext(ctx)
+	received, _ := ctx.Value(receivedKey{}).(*atomic.Int64)
+
+	switch rs := rs.(type) {
+	case *stats.OutPayload:
+		setRequested(span, rs.Payload)
+	case *stats.InPayload:
+		if c, ok := rs.Payload.(primesmsg.Contents); ok && received != nil {
+			received.Add(int64(len(c.GetContents())))
+		}
+		if _, ok := rs.Payload.(primesmsg.Value); ok && received != nil {
+			received.Add(1)
+		}
+	case *stats.End:
+		// The span ends when the call does, so it is annotated first.
+		if received != nil {
+			span.SetAttributes(ReturnedKey.Int64(received.Load()))
+		}
+	}
+
+	h.Handler.HandleRPC(ctx, rs)
+}
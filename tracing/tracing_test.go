@@ -0,0 +1,230 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	collectortracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/devries/grpc-tutorial/api"
+	"github.com/devries/grpc-tutorial/apistream"
+)
+
+// collector stands in for an OpenTelemetry collector, keeping every span it
+// receives.
+type collector struct {
+	collectortracepb.UnimplementedTraceServiceServer
+
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (c *collector) Export(ctx context.Context, req *collectortracepb.ExportTraceServiceRequest) (*collectortracepb.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+
+	return &collectortracepb.ExportTraceServiceResponse{}, nil
+}
+
+// find returns the span with the given name and kind.
+func (c *collector) find(name string, kind tracepb.Span_SpanKind) *tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, s := range c.spans {
+		if s.Name == name && s.Kind == kind {
+			return s
+		}
+	}
+	return nil
+}
+
+// count returns the number of spans of the given kind.
+func (c *collector) count(kind tracepb.Span_SpanKind) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for _, s := range c.spans {
+		if s.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+func attr(s *tracepb.Span, key string) *commonpb.AnyValue {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+	return nil
+}
+
+// startCollector runs a collector and points the exporter at it.
+func startCollector(t *testing.T) *collector {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+
+	c := &collector{}
+	s := grpc.NewServer()
+	collectortracepb.RegisterTraceServiceServer(s, c)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://"+lis.Addr().String())
+
+	return c
+}
+
+type primesServer struct {
+	api.UnimplementedPrimesServer
+}
+
+func (s *primesServer) GetPrimes(ctx context.Context, in *api.PrimeCount) (*api.PrimeNumbers, error) {
+	return &api.PrimeNumbers{Contents: []int64{2, 3, 5, 7, 11}[:in.Number]}, nil
+}
+
+type streamServer struct {
+	apistream.UnimplementedPrimeStreamServer
+}
+
+func (s *streamServer) GetPrimes(in *apistream.PrimeCount, stream apistream.PrimeStream_GetPrimesServer) error {
+	for i, p := range []int64{2, 3, 5, 7, 11}[:in.Number] {
+		if err := stream.Send(&apistream.PrimeNumber{Count: int64(i + 1), Value: p}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authInterceptor stands in for the AuthenticationInterceptor of server_five.
+func authInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	SetAuthorized(ctx, true)
+	return handler(ctx, req)
+}
+
+func dial(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	opts := append(ServerOptions(), grpc.ChainUnaryInterceptor(authInterceptor))
+	s := grpc.NewServer(opts...)
+	api.RegisterPrimesServer(s, &primesServer{})
+	apistream.RegisterPrimeStreamServer(s, &streamServer{})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	dialOpts := []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+	dialOpts = append(dialOpts, DialOptions()...)
+
+	conn, err := grpc.Dial("bufnet", dialOpts...)
+	if err != nil {
+		t.Fatalf("dial failed: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func TestTracing(t *testing.T) {
+	c := startCollector(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	shutdown, err := Setup(ctx, "tracing-test")
+	if err != nil {
+		t.Fatalf("Setup failed: %s", err)
+	}
+
+	conn := dial(t)
+
+	if _, err := api.NewPrimesClient(conn).GetPrimes(ctx, &api.PrimeCount{Number: 3}); err != nil {
+		t.Fatalf("GetPrimes failed: %s", err)
+	}
+
+	stream, err := apistream.NewPrimeStreamClient(conn).GetPrimes(ctx, &apistream.PrimeCount{Number: 4})
+	if err != nil {
+		t.Fatalf("stream GetPrimes failed: %s", err)
+	}
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("stream failed: %s", err)
+		}
+	}
+
+	// Shutting down flushes the spans to the collector.
+	if err := shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed: %s", err)
+	}
+
+	tests := []struct {
+		name       string
+		kind       tracepb.Span_SpanKind
+		requested  int64
+		returned   int64
+		authorized bool
+		peer       bool
+	}{
+		{"api.Primes/GetPrimes", tracepb.Span_SPAN_KIND_SERVER, 3, 3, true, true},
+		{"api.Primes/GetPrimes", tracepb.Span_SPAN_KIND_CLIENT, 3, 3, false, false},
+		{"apistream.PrimeStream/GetPrimes", tracepb.Span_SPAN_KIND_SERVER, 4, 4, false, true},
+		{"apistream.PrimeStream/GetPrimes", tracepb.Span_SPAN_KIND_CLIENT, 4, 4, false, false},
+	}
+
+	// Each call is traced by one span on each side.
+	if n := c.count(tracepb.Span_SPAN_KIND_CLIENT); n != 2 {
+		t.Errorf("%d client spans, want 2", n)
+	}
+
+	for _, tc := range tests {
+		s := c.find(tc.name, tc.kind)
+		if s == nil {
+			t.Errorf("no %s span named %s", tc.kind, tc.name)
+			continue
+		}
+
+		if v := attr(s, string(RequestedKey)); v.GetIntValue() != tc.requested {
+			t.Errorf("%s: %s = %v, want %d", tc.name, RequestedKey, v, tc.requested)
+		}
+		if v := attr(s, string(ReturnedKey)); v.GetIntValue() != tc.returned {
+			t.Errorf("%s: %s = %v, want %d", tc.name, ReturnedKey, v, tc.returned)
+		}
+		if v := attr(s, string(AuthorizedKey)); v.GetBoolValue() != tc.authorized {
+			t.Errorf("%s: %s = %v, want %t", tc.name, AuthorizedKey, v, tc.authorized)
+		}
+		if v := attr(s, string(PeerKey)); (v.GetStringValue() != "") != tc.peer {
+			t.Errorf("%s: %s = %v", tc.name, PeerKey, v)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+package validate
+
+//go:generate protoc -I .. --go_out=paths=source_relative:.. ../validate/validate.proto
@@ -0,0 +1,180 @@
+// Package validate checks requests against the constraints declared on their
+// fields in the .proto files, such as
+//
+//	int64 number = 1 [(validate.field).int64 = {gte: 0, lte: 500}];
+//
+// so that the limits of each RPC are defined once, next to the messages, and
+// enforced by an interceptor before the request reaches the server. Methods
+// sharing a request message may tighten its constraints with the
+// (validate.request) method option. Invalid requests fail with
+// InvalidArgument and a BadRequest detail listing every field which broke its
+// constraints.
+package validate
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/devries/grpc-tutorial/richstatus"
+)
+
+// Check returns an InvalidArgument error if m breaks any of the constraints
+// declared on its fields.
+func Check(m proto.Message) error {
+	return report(check(m.ProtoReflect(), ""))
+}
+
+// CheckMethod is like Check, but also applies the constraints which
+// fullMethod, such as "/primes.v1.PrimesService/GetPrimes", declares on the
+// fields of its request m.
+func CheckMethod(fullMethod string, m proto.Message) error {
+	r := m.ProtoReflect()
+	violations := check(r, "")
+
+	fields := r.Descriptor().Fields()
+	for _, rc := range methodConstraints(fullMethod) {
+		fd := fields.ByName(protoreflect.Name(rc.GetField()))
+		if fd == nil {
+			continue
+		}
+		violations = append(violations, checkField(r, fd, string(fd.Name()), rc.GetConstraints())...)
+	}
+
+	return report(violations)
+}
+
+func report(violations []richstatus.Violation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+
+	descriptions := make([]string, len(violations))
+	for i, v := range violations {
+		descriptions[i] = v.Field + ": " + v.Description
+	}
+	msg := "invalid request: " + strings.Join(descriptions, ", ")
+
+	return richstatus.BadRequest(msg, violations...)
+}
+
+// methodConstraints returns the constraints declared by fullMethod on the
+// fields of its request.
+func methodConstraints(fullMethod string) []*RequestConstraints {
+	name := strings.ReplaceAll(strings.TrimPrefix(fullMethod, "/"), "/", ".")
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil
+	}
+	md, ok := d.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil
+	}
+
+	rcs, _ := proto.GetExtension(md.Options(), E_Request).([]*RequestConstraints)
+	return rcs
+}
+
+func check(m protoreflect.Message, prefix string) []richstatus.Violation {
+	var violations []richstatus.Violation
+
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		path := prefix + string(fd.Name())
+
+		// Constraints apply to nested messages which are present, but not to
+		// lists or maps.
+		if fd.Kind() == protoreflect.MessageKind && !fd.IsList() && !fd.IsMap() {
+			if m.Has(fd) {
+				violations = append(violations, check(m.Get(fd).Message(), path+".")...)
+			}
+			continue
+		}
+
+		c, ok := proto.GetExtension(fd.Options(), E_Field).(*FieldConstraints)
+		if !ok || c == nil {
+			continue
+		}
+		violations = append(violations, checkField(m, fd, path, c)...)
+	}
+
+	return violations
+}
+
+// checkField checks the value of field fd of m, reported as path, against c.
+func checkField(m protoreflect.Message, fd protoreflect.FieldDescriptor, path string, c *FieldConstraints) []richstatus.Violation {
+	var violations []richstatus.Violation
+
+	if rules := c.GetInt64(); rules != nil && fd.Kind() == protoreflect.Int64Kind && !fd.IsList() {
+		v := m.Get(fd).Int()
+		if rules.Gte != nil && v < rules.GetGte() {
+			violations = append(violations, richstatus.Violation{
+				Field:       path,
+				Description: fmt.Sprintf("value must be greater than or equal to %d", rules.GetGte()),
+			})
+		}
+		if rules.Lte != nil && v > rules.GetLte() {
+			violations = append(violations, richstatus.Violation{
+				Field:       path,
+				Description: fmt.Sprintf("value must be less than or equal to %d", rules.GetLte()),
+			})
+		}
+	}
+
+	return violations
+}
+
+// ServerOptions returns the interceptors which check every request.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor),
+	}
+}
+
+func checkRequest(ctx context.Context, fullMethod string, req interface{}) error {
+	m, ok := req.(proto.Message)
+	if !ok {
+		return nil
+	}
+
+	err := CheckMethod(fullMethod, m)
+	if err != nil {
+		slog.WarnContext(ctx, "Invalid request", "error", status.Convert(err).Message())
+	}
+	return err
+}
+
+// UnaryServerInterceptor checks unary requests.
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := checkRequest(ctx, info.FullMethod, req); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// StreamServerInterceptor checks each message received on a stream.
+func StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &validatingStream{ServerStream: ss, method: info.FullMethod})
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+	method string
+}
+
+func (s *validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return checkRequest(s.Context(), s.method, m)
+}
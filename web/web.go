@@ -0,0 +1,288 @@
+// Package web serves the services to browsers. Browsers cannot speak gRPC
+// directly, so calls arrive using the gRPC-Web or Connect protocols, over
+// HTTP/1.1 or HTTP/2, and are forwarded to the server's own gRPC port, where
+// they pass through the same interceptors as any other call, on behalf of
+// the HTTP client rather than the web server itself. Both unary and
+// server streaming calls are supported, and ordinary gRPC clients may call
+// the web port too.
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"connectrpc.com/connect"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/devries/grpc-tutorial/api"
+	"github.com/devries/grpc-tutorial/api/apiconnect"
+	"github.com/devries/grpc-tutorial/apistream"
+	"github.com/devries/grpc-tutorial/apistream/apistreamconnect"
+	"github.com/devries/grpc-tutorial/forwarded"
+	"github.com/devries/grpc-tutorial/logging"
+	primesv1 "github.com/devries/grpc-tutorial/primes/v1"
+	"github.com/devries/grpc-tutorial/primes/v1/primesv1connect"
+)
+
+// RegisterFunc mounts the handler of one service, forwarding calls to conn.
+type RegisterFunc func(mux *http.ServeMux, conn *grpc.ClientConn)
+
+// PrimesService mounts the primes.v1.PrimesService service.
+func PrimesService(mux *http.ServeMux, conn *grpc.ClientConn) {
+	mux.Handle(primesv1connect.NewPrimesServiceHandler(&primesServiceProxy{client: primesv1.NewPrimesServiceClient(conn)}))
+}
+
+// Primes mounts the api.Primes service.
+func Primes(mux *http.ServeMux, conn *grpc.ClientConn) {
+	mux.Handle(apiconnect.NewPrimesHandler(&primesProxy{client: api.NewPrimesClient(conn)}))
+}
+
+// PrimeStream mounts the apistream.PrimeStream service.
+func PrimeStream(mux *http.ServeMux, conn *grpc.ClientConn) {
+	mux.Handle(apistreamconnect.NewPrimeStreamHandler(&primeStreamProxy{client: apistream.NewPrimeStreamClient(conn)}))
+}
+
+// Handler returns an HTTP handler which forwards calls to the gRPC server at
+// target, dialed with creds. Browsers on the given origins may call it from
+// other sites; "*" allows every origin.
+func Handler(ctx context.Context, target string, creds credentials.TransportCredentials, origins []string, register ...RegisterFunc) (http.Handler, error) {
+	conn, err := grpc.DialContext(ctx, target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	for _, r := range register {
+		r(mux, conn)
+	}
+
+	return withCORS(forwarded.Handler(mux), origins), nil
+}
+
+// ServeFromEnv serves the services in the background on the port in the
+// WEB_PORT environment variable, forwarding calls to the gRPC server at
+// target. WEB_ALLOWED_ORIGINS is a comma separated list of the origins whose
+// pages may call the services. If tlsConfig is not nil the services are
+// served over HTTPS with it, and otherwise HTTP/2 is accepted without TLS.
+// It returns the HTTP server, so that it can be shut down, or nil if
+// WEB_PORT is unset and nothing is served.
+func ServeFromEnv(target string, creds credentials.TransportCredentials, tlsConfig *tls.Config, register ...RegisterFunc) (*http.Server, error) {
+	port := os.Getenv("WEB_PORT")
+	if port == "" {
+		return nil, nil
+	}
+
+	var origins []string
+	for _, o := range strings.Split(os.Getenv("WEB_ALLOWED_ORIGINS"), ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+
+	handler, err := Handler(context.Background(), target, creds, origins, register...)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{Addr: ":" + port}
+	if tlsConfig != nil {
+		// The server adds HTTP/2 to the protocols of its TLS configuration,
+		// so give it a copy of its own.
+		srv.TLSConfig = tlsConfig.Clone()
+		srv.Handler = handler
+	} else {
+		srv.Handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	log.Printf("Serving gRPC-Web and Connect on port %s", port)
+	go func() {
+		var err error
+		if tlsConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			log.Fatalf("Failed to serve gRPC-Web and Connect: %s", err)
+		}
+	}()
+
+	return srv, nil
+}
+
+// CORS headers allowing browsers to make gRPC-Web and Connect calls.
+var (
+	allowedHeaders = strings.Join([]string{
+		"Content-Type", "Connect-Protocol-Version", "Connect-Timeout-Ms",
+		"Grpc-Timeout", "X-Grpc-Web", "X-User-Agent",
+		"Authorization", logging.RequestIDHeader,
+	}, ", ")
+	exposedHeaders = strings.Join([]string{
+		"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin",
+		logging.RequestIDHeader, "X-Quota-Remaining-Hourly", "X-Quota-Remaining-Daily",
+		"Retry-After",
+	}, ", ")
+)
+
+// withCORS answers preflight requests from the allowed origins and lets
+// their pages read the responses.
+func withCORS(h http.Handler, origins []string) http.Handler {
+	allowed := func(origin string) bool {
+		for _, o := range origins {
+			if o == "*" || o == origin {
+				return true
+			}
+		}
+		return false
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !allowed(origin) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+			w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+			w.Header().Set("Access-Control-Max-Age", "7200")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// forwardedHeaders are passed on from the browser to the gRPC server.
+var forwardedHeaders = []string{"authorization", logging.RequestIDHeader}
+
+// outgoing adds the forwarded headers to the outgoing metadata of ctx, which
+// already identifies the client.
+func outgoing(ctx context.Context, h http.Header) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	for _, k := range forwardedHeaders {
+		if v := h.Values(k); len(v) > 0 {
+			md.Set(k, v...)
+		}
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// copyMetadata copies the metadata the gRPC server sent into HTTP headers,
+// leaving out the transport's own headers and binary values.
+func copyMetadata(dst http.Header, md metadata.MD) {
+	for k, vs := range md {
+		if k == "content-type" || strings.HasPrefix(k, "grpc-") || strings.HasSuffix(k, "-bin") {
+			continue
+		}
+		for _, v := range vs {
+			dst.Add(k, v)
+		}
+	}
+}
+
+// connectError converts an error from the gRPC server, keeping its code,
+// details, and metadata.
+func connectError(err error, header, trailer metadata.MD) error {
+	st := status.Convert(err)
+	cerr := connect.NewError(connect.Code(st.Code()), errors.New(st.Message()))
+	for _, d := range st.Proto().GetDetails() {
+		if detail, err := connect.NewErrorDetail(d); err == nil {
+			cerr.AddDetail(detail)
+		}
+	}
+	copyMetadata(cerr.Meta(), header)
+	copyMetadata(cerr.Meta(), trailer)
+	return cerr
+}
+
+// unary forwards a unary call to the gRPC server with call, copying the
+// metadata it sends back.
+func unary[Req, Res any](ctx context.Context, req *connect.Request[Req], call func(context.Context, *Req, ...grpc.CallOption) (*Res, error)) (*connect.Response[Res], error) {
+	var header, trailer metadata.MD
+	resp, err := call(outgoing(ctx, req.Header()), req.Msg, grpc.Header(&header), grpc.Trailer(&trailer))
+	if err != nil {
+		return nil, connectError(err, header, trailer)
+	}
+
+	res := connect.NewResponse(resp)
+	copyMetadata(res.Header(), header)
+	copyMetadata(res.Trailer(), trailer)
+	return res, nil
+}
+
+// serverStream forwards a server streaming call to the gRPC server with
+// call, copying the messages and metadata it sends back to stream.
+func serverStream[Req, Res any](ctx context.Context, req *connect.Request[Req], stream *connect.ServerStream[Res], call func(context.Context, *Req, ...grpc.CallOption) (grpc.ServerStreamingClient[Res], error)) error {
+	cs, err := call(outgoing(ctx, req.Header()), req.Msg)
+	if err != nil {
+		return connectError(err, nil, nil)
+	}
+
+	// The headers must be copied before the first message is sent.
+	header, err := cs.Header()
+	if err == nil {
+		copyMetadata(stream.ResponseHeader(), header)
+	}
+
+	for {
+		msg, err := cs.Recv()
+		if err == io.EOF {
+			copyMetadata(stream.ResponseTrailer(), cs.Trailer())
+			return nil
+		}
+		if err != nil {
+			return connectError(err, header, cs.Trailer())
+		}
+
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+	}
+}
+
+type primesServiceProxy struct {
+	client primesv1.PrimesServiceClient
+}
+
+func (p *primesServiceProxy) GetPrimes(ctx context.Context, req *connect.Request[primesv1.PrimeCount]) (*connect.Response[primesv1.PrimeNumbers], error) {
+	return unary(ctx, req, p.client.GetPrimes)
+}
+
+func (p *primesServiceProxy) StreamPrimes(ctx context.Context, req *connect.Request[primesv1.PrimeCount], stream *connect.ServerStream[primesv1.PrimeNumber]) error {
+	return serverStream(ctx, req, stream, p.client.StreamPrimes)
+}
+
+type primesProxy struct {
+	client api.PrimesClient
+}
+
+func (p *primesProxy) GetPrimes(ctx context.Context, req *connect.Request[api.PrimeCount]) (*connect.Response[api.PrimeNumbers], error) {
+	return unary(ctx, req, p.client.GetPrimes)
+}
+
+type primeStreamProxy struct {
+	client apistream.PrimeStreamClient
+}
+
+func (p *primeStreamProxy) GetPrimes(ctx context.Context, req *connect.Request[apistream.PrimeCount], stream *connect.ServerStream[apistream.PrimeNumber]) error {
+	return serverStream(ctx, req, stream, p.client.GetPrimes)
+}
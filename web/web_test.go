@@ -0,0 +1,235 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/devries/grpc-tutorial/forwarded"
+	"github.com/devries/grpc-tutorial/generator"
+	primesv1 "github.com/devries/grpc-tutorial/primes/v1"
+	"github.com/devries/grpc-tutorial/primes/v1/primesv1connect"
+	"github.com/devries/grpc-tutorial/servertest"
+	"github.com/devries/grpc-tutorial/validate"
+)
+
+// origin is the only origin allowed to call the test server.
+const origin = "https://example.com"
+
+// service sends metadata with every call, some of which must not reach the
+// browser.
+type service struct {
+	*generator.Service
+}
+
+var (
+	header  = metadata.Pairs("x-header", "h", "x-header-bin", "b")
+	trailer = metadata.Pairs("x-trailer", "t", "x-trailer-bin", "b")
+)
+
+func (s service) GetPrimes(ctx context.Context, in *primesv1.PrimeCount) (*primesv1.PrimeNumbers, error) {
+	grpc.SetHeader(ctx, header)
+	grpc.SetTrailer(ctx, trailer)
+	return s.Service.GetPrimes(ctx, in)
+}
+
+func (s service) StreamPrimes(in *primesv1.PrimeCount, stream primesv1.PrimesService_StreamPrimesServer) error {
+	stream.SetHeader(header)
+	stream.SetTrailer(trailer)
+	return s.Service.StreamPrimes(in, stream)
+}
+
+// serve runs the primes service over a loopback port, and returns the URL of
+// a web server forwarding calls to it.
+func serve(t *testing.T) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	opts := append(forwarded.ServerOptions(nil), validate.ServerOptions()...)
+	s := grpc.NewServer(opts...)
+	primesv1.RegisterPrimesServiceServer(s, service{generator.NewService(nil)})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	h, err := Handler(ctx, lis.Addr().String(), insecure.NewCredentials(), []string{origin}, PrimesService)
+	if err != nil {
+		t.Fatalf("could not create handler: %s", err)
+	}
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// checkMetadata checks that the metadata the gRPC server sent arrived, less
+// its binary values.
+func checkMetadata(t *testing.T, name string, h http.Header, key string) {
+	t.Helper()
+
+	if got := h.Get(key); got == "" {
+		t.Errorf("%s lacks %s", name, key)
+	}
+	if got := h.Get(key + "-bin"); got != "" {
+		t.Errorf("%s has %s-bin: %q", name, key, got)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	url := serve(t)
+
+	tests := []struct {
+		name   string
+		origin string
+		status int
+		allow  string
+	}{
+		{"allowed", origin, http.StatusNoContent, origin},
+		{"disallowed", "https://example.org", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodOptions, url+primesv1connect.PrimesServiceGetPrimesProcedure, nil)
+			r.Header.Set("Origin", tc.origin)
+			r.Header.Set("Access-Control-Request-Method", "POST")
+			r.Header.Set("Access-Control-Request-Headers", "content-type")
+			resp, err := http.DefaultClient.Do(r)
+			if err != nil {
+				t.Fatalf("preflight failed: %s", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.status {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tc.status)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Origin"); got != tc.allow {
+				t.Errorf("got Access-Control-Allow-Origin %q, want %q", got, tc.allow)
+			}
+			if tc.allow != "" && resp.Header.Get("Access-Control-Allow-Methods") == "" {
+				t.Error("preflight does not allow any methods")
+			}
+		})
+	}
+}
+
+func TestConnectUnary(t *testing.T) {
+	client := primesv1connect.NewPrimesServiceClient(http.DefaultClient, serve(t))
+
+	resp, err := client.GetPrimes(servertest.Context(t), connect.NewRequest(&primesv1.PrimeCount{Number: 5}))
+	if err != nil {
+		t.Fatalf("GetPrimes failed: %s", err)
+	}
+
+	want := []int64{2, 3, 5, 7, 11}
+	got := resp.Msg.GetContents()
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+			break
+		}
+	}
+	checkMetadata(t, "header", resp.Header(), "x-header")
+	checkMetadata(t, "trailer", resp.Trailer(), "x-trailer")
+}
+
+func TestGRPCWebStream(t *testing.T) {
+	client := primesv1connect.NewPrimesServiceClient(http.DefaultClient, serve(t), connect.WithGRPCWeb())
+
+	stream, err := client.StreamPrimes(servertest.Context(t), connect.NewRequest(&primesv1.PrimeCount{Number: 3}))
+	if err != nil {
+		t.Fatalf("StreamPrimes failed: %s", err)
+	}
+	defer stream.Close()
+
+	want := []int64{2, 3, 5}
+	var got []int64
+	for stream.Receive() {
+		got = append(got, stream.Msg().GetValue())
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream failed: %s", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+			break
+		}
+	}
+	checkMetadata(t, "header", stream.ResponseHeader(), "x-header")
+	checkMetadata(t, "trailer", stream.ResponseTrailer(), "x-trailer")
+}
+
+// Errors keep their code, details, and metadata on the way to the browser.
+func TestErrorDetails(t *testing.T) {
+	client := primesv1connect.NewPrimesServiceClient(http.DefaultClient, serve(t))
+
+	_, err := client.GetPrimes(servertest.Context(t), connect.NewRequest(&primesv1.PrimeCount{Number: -1}))
+	var cerr *connect.Error
+	if !errors.As(err, &cerr) {
+		t.Fatalf("got %v, want a Connect error", err)
+	}
+	if cerr.Code() != connect.CodeInvalidArgument {
+		t.Errorf("got code %s, want %s", cerr.Code(), connect.CodeInvalidArgument)
+	}
+
+	var found bool
+	for _, d := range cerr.Details() {
+		v, err := d.Value()
+		if err != nil {
+			t.Errorf("could not decode %s: %s", d.Type(), err)
+			continue
+		}
+		if br, ok := v.(*errdetails.BadRequest); ok && len(br.GetFieldViolations()) > 0 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("got details %v, want a BadRequest", cerr.Details())
+	}
+}
+
+func TestCopyMetadata(t *testing.T) {
+	md := metadata.Pairs(
+		"content-type", "application/grpc",
+		"grpc-accept-encoding", "gzip",
+		"x-quota-remaining-daily", "9",
+		"x-request-id", "abc",
+		"x-trace-bin", "\x00\x01",
+	)
+
+	h := make(http.Header)
+	copyMetadata(h, md)
+
+	want := http.Header{
+		"X-Quota-Remaining-Daily": {"9"},
+		"X-Request-Id":            {"abc"},
+	}
+	if len(h) != len(want) {
+		t.Errorf("got %v, want %v", h, want)
+	}
+	for k, v := range want {
+		if got := h.Values(k); len(got) != 1 || got[0] != v[0] {
+			t.Errorf("got %s %v, want %v", k, got, v)
+		}
+	}
+}